@@ -1,13 +1,59 @@
 package main
 
 import (
+	"context"
+	"liblink/internal/db"
+	"liblink/internal/db/migrations"
 	"liblink/internal/global"
-	_ "liblink/internal/global"
+	"liblink/internal/jobs"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/user"
 	"liblink/internal/router"
+	"liblink/internal/search"
 	"log"
+	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	if global.DB == nil {
+		log.Fatal("database connection failed")
+	}
+	// 数据库结构不是最新版本时拒绝启动
+	if err := migrations.Check(global.DB); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := db.Seed(global.DB, global.Conf.InitAdmin.Email, global.Conf.InitAdmin.Password, global.Conf.BcryptCost); err != nil {
+		log.Fatal("seed error: ", err.Error())
+	}
+
+	if h := global.Conf.Storage.VerifyIntervalHour; h > 0 {
+		jobs.Every(context.Background(), time.Duration(h)*time.Hour, "verify-attachments", jobs.VerifyAttachments)
+	}
+	if m := global.Conf.Subscription.IntervalMinute; m > 0 {
+		jobs.Every(context.Background(), time.Duration(m)*time.Minute, "notify-subscriptions", jobs.NotifySubscriptions)
+	}
+	// 新建的 Bleve 索引在后台写入全部档案
+	if index, ok := global.Search.(*search.Bleve); ok && index.Created {
+		go func() {
+			n, err := index.Rebuild(context.Background(), global.DB)
+			if err != nil {
+				global.Logger.Error("rebuild search index error: " + err.Error())
+				return
+			}
+			global.Logger.Info("search index rebuilt, " + strconv.Itoa(n) + " archives")
+		}()
+	}
+	if global.OCR != nil {
+		jobs.StartOCR(context.Background(), global.OCR, global.Conf.OCR.Workers, time.Duration(global.Conf.OCR.TimeoutSec)*time.Second)
+	}
+
 	r := router.Router()
 
 	if err := r.Run(global.Conf.Port); err != nil {
@@ -15,3 +61,96 @@ func main() {
 		return
 	}
 }
+
+// runCommand 运维子命令
+//
+//	migrate up          执行全部未执行的迁移
+//	migrate down [n]    回滚最近的 n 个迁移，默认 1 个
+//	migrate status      查看迁移状态
+//	encrypt-pii         迁移后才启用密钥环或轮换主密钥时，加密或重新加密敏感字段及两步验证密钥
+//	verify-attachments  校验全部附件版本的校验和
+//	search-reindex      重建档案检索索引
+func runCommand(name string, args []string) {
+	if global.DB == nil {
+		log.Fatal("database connection failed")
+	}
+
+	switch name {
+	case "migrate":
+		runMigrate(args)
+	case "encrypt-pii":
+		n, err := archive.EncryptPII(global.DB, 500)
+		if err != nil {
+			log.Fatal("encrypt pii error: ", err.Error())
+		}
+		log.Printf("encrypt pii done, %d archives updated", n)
+		n, err = user.EncryptTOTPSecrets(global.DB)
+		if err != nil {
+			log.Fatal("encrypt totp secrets error: ", err.Error())
+		}
+		log.Printf("encrypt totp secrets done, %d users updated", n)
+	case "search-reindex":
+		n, err := global.Search.Rebuild(context.Background(), global.DB)
+		if err != nil {
+			log.Fatal("rebuild search index error: ", err.Error())
+		}
+		log.Printf("rebuild search index done, %d archives indexed", n)
+	case "verify-attachments":
+		if err := jobs.VerifyAttachments(context.Background()); err != nil {
+			log.Fatal("verify attachments error: ", err.Error())
+		}
+	default:
+		log.Fatal("unknown command: ", name)
+	}
+}
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: liblink migrate up|down [n]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := migrations.Up(global.DB)
+		for _, m := range ran {
+			log.Printf("migrated %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("migrate up error: ", err.Error())
+		}
+		if err := db.Seed(global.DB, global.Conf.InitAdmin.Email, global.Conf.InitAdmin.Password, global.Conf.BcryptCost); err != nil {
+			log.Fatal("seed error: ", err.Error())
+		}
+		log.Printf("migrate up done, %d applied", len(ran))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				log.Fatal("invalid steps: ", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := migrations.Down(global.DB, steps)
+		for _, m := range rolledBack {
+			log.Printf("rolled back %d %s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("migrate down error: ", err.Error())
+		}
+	case "status":
+		list, err := migrations.List(global.DB)
+		if err != nil {
+			log.Fatal("migrate status error: ", err.Error())
+		}
+		for _, s := range list {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			log.Printf("%04d %-32s %s", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal("usage: liblink migrate up|down [n]|status")
+	}
+}
@@ -1,17 +1,250 @@
 package config
 
 import (
+	"errors"
 	"gopkg.in/yaml.v2"
 	"os"
+	"strings"
+	"unicode"
 )
 
 type Conf struct {
-	DatabaseHost     string `yaml:"database-host"`
-	DatabaseUser     string `yaml:"database-user"`
-	DatabasePassword string `yaml:"database-password"`
-	JWTKey           string `yaml:"jwt-key"`
-	Host             string `yaml:"host"`
-	Port             string `yaml:"port"`
+	DatabaseHost     string          `yaml:"database-host"`
+	DatabaseUser     string          `yaml:"database-user"`
+	DatabasePassword string          `yaml:"database-password"`
+	JWTKey           string          `yaml:"jwt-key"`
+	Host             string          `yaml:"host"`
+	Port             string          `yaml:"port"`
+	SiteURL          string          `yaml:"site-url"`
+	BcryptCost       int             `yaml:"bcrypt-cost"`
+	InviteExpireHour int             `yaml:"invite-expire-hour"`
+	FileNoTemplate   string          `yaml:"file-no-template"` // 默认档案编号模板，档案类型可单独设置
+	InitAdmin        InitAdmin       `yaml:"init-admin"`
+	PasswordPolicy   PasswordPolicy  `yaml:"password-policy"`
+	LoginProtection  LoginProtection `yaml:"login-protection"`
+	Mail             Mail            `yaml:"mail"`
+	AuthBackends     []string        `yaml:"auth-backends"`
+	LDAP             LDAP            `yaml:"ldap"`
+	OIDC             OIDC            `yaml:"oidc"`
+	Encryption       Encryption      `yaml:"encryption"`
+	Storage          Storage         `yaml:"storage"`
+	OCR              OCR             `yaml:"ocr"`
+	Search           Search          `yaml:"search"`
+	Subscription     Subscription    `yaml:"subscription"`
+	Label            Label           `yaml:"label"`
+}
+
+// InitAdmin 初始管理员，仅在用户表为空时创建
+type InitAdmin struct {
+	Email    string `yaml:"email"`
+	Password string `yaml:"password"`
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength     int  `yaml:"min-length"`
+	RequireUpper  bool `yaml:"require-upper"`
+	RequireLower  bool `yaml:"require-lower"`
+	RequireDigit  bool `yaml:"require-digit"`
+	RequireSymbol bool `yaml:"require-symbol"`
+}
+
+// LoginProtection 登录防暴力破解配置
+type LoginProtection struct {
+	MaxFailures     int `yaml:"max-failures"`      // 账号连续失败次数达到后锁定
+	IPMaxFailures   int `yaml:"ip-max-failures"`   // 单个 IP 在窗口期内的失败次数上限
+	WindowMinute    int `yaml:"window-minute"`     // 失败次数统计窗口
+	LockMinute      int `yaml:"lock-minute"`       // 锁定时长
+	BaseDelaySecond int `yaml:"base-delay-second"` // 失败后的初始等待时间，每次失败翻倍
+	MaxDelaySecond  int `yaml:"max-delay-second"`  // 最长等待时间
+}
+
+// LDAP 目录认证配置
+type LDAP struct {
+	URL              string            `yaml:"url"`
+	StartTLS         bool              `yaml:"start-tls"`
+	InsecureTLS      bool              `yaml:"insecure-tls"`
+	BindDN           string            `yaml:"bind-dn"`
+	BindPassword     string            `yaml:"bind-password"`
+	BaseDN           string            `yaml:"base-dn"`
+	UserFilter       string            `yaml:"user-filter"` // %s 替换为登录名
+	EmailAttr        string            `yaml:"email-attr"`
+	NameAttr         string            `yaml:"name-attr"`
+	GroupAttr        string            `yaml:"group-attr"`
+	DefaultRole      string            `yaml:"default-role"`
+	GroupRoles       []GroupMapping    `yaml:"group-roles"`       // 按顺序匹配第一个
+	GroupPermissions []GroupPermission `yaml:"group-permissions"` // 全部匹配的用户组合并
+}
+
+// OIDC 单点登录配置，授权码模式 + PKCE
+type OIDC struct {
+	Enabled          bool              `yaml:"enabled"`
+	Issuer           string            `yaml:"issuer"`
+	ClientID         string            `yaml:"client-id"`
+	ClientSecret     string            `yaml:"client-secret"`
+	RedirectURL      string            `yaml:"redirect-url"` // 本系统的回调地址 /auth/oidc/callback
+	Scopes           []string          `yaml:"scopes"`
+	LoginClaim       string            `yaml:"login-claim"`
+	EmailClaim       string            `yaml:"email-claim"`
+	NameClaim        string            `yaml:"name-claim"`
+	GroupsClaim      string            `yaml:"groups-claim"`
+	FrontendURL      string            `yaml:"frontend-url"` // 登录成功后携带 token 跳转的前端地址
+	DefaultRole      string            `yaml:"default-role"`
+	GroupRoles       []GroupMapping    `yaml:"group-roles"`
+	GroupPermissions []GroupPermission `yaml:"group-permissions"`
+}
+
+// Encryption 敏感字段加密配置，密钥均为 base64 编码的 32 字节随机数
+// 也可通过环境变量 LIBLINK_ENCRYPTION_PRIMARY、LIBLINK_ENCRYPTION_KEYS（id:key,id:key）、
+// LIBLINK_ENCRYPTION_INDEX_KEY 提供，环境变量优先
+type Encryption struct {
+	PrimaryKey string            `yaml:"primary-key"` // 新数据使用的密钥ID
+	Keys       map[string]string `yaml:"keys"`        // 密钥ID -> 密钥，轮换后旧密钥需保留用于解密
+	IndexKey   string            `yaml:"index-key"`   // 盲索引密钥，设置后不可更换
+}
+
+// Enabled 是否配置了字段加密
+func (e Encryption) Enabled() bool {
+	return e.PrimaryKey != ""
+}
+
+// applyEnv 使用环境变量覆盖加密配置，避免密钥写入配置文件
+func (e *Encryption) applyEnv() {
+	if v := os.Getenv("LIBLINK_ENCRYPTION_PRIMARY"); v != "" {
+		e.PrimaryKey = v
+	}
+	if v := os.Getenv("LIBLINK_ENCRYPTION_KEYS"); v != "" {
+		e.Keys = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			if id, key, ok := strings.Cut(strings.TrimSpace(pair), ":"); ok {
+				e.Keys[id] = key
+			}
+		}
+	}
+	if v := os.Getenv("LIBLINK_ENCRYPTION_INDEX_KEY"); v != "" {
+		e.IndexKey = v
+	}
+}
+
+// Storage 档案附件存储配置，附件不经由 /static 对外提供，只能通过下载接口校验权限后获取
+// S3 密钥也可通过环境变量 LIBLINK_S3_ACCESS_KEY、LIBLINK_S3_SECRET_KEY 提供
+type Storage struct {
+	Backend   string `yaml:"backend"`     // local 或 s3
+	LocalRoot string `yaml:"local-root"`  // 本地存储目录
+	MaxSizeMB int    `yaml:"max-size-mb"` // 单个附件大小上限
+	S3        S3     `yaml:"s3"`
+
+	VerifyIntervalHour int `yaml:"verify-interval-hour"` // 附件完整性校验间隔，0 为不自动校验
+}
+
+// S3 兼容 S3 协议的对象存储
+type S3 struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access-key"`
+	SecretKey string `yaml:"secret-key"`
+	PathStyle bool   `yaml:"path-style"` // MinIO 等自建存储需要开启
+}
+
+func (s *Storage) applyEnv() {
+	if v := os.Getenv("LIBLINK_S3_ACCESS_KEY"); v != "" {
+		s.S3.AccessKey = v
+	}
+	if v := os.Getenv("LIBLINK_S3_SECRET_KEY"); v != "" {
+		s.S3.SecretKey = v
+	}
+}
+
+// OCR 附件文字识别配置，识别出的文字用于档案全文检索
+// 使用 tesseract 时需安装 tesseract 及对应语言包，识别 PDF 还需要 poppler 的 pdftoppm
+type OCR struct {
+	Engine     string `yaml:"engine"`      // tesseract 或 none
+	Command    string `yaml:"command"`     // tesseract 可执行文件
+	PDFCommand string `yaml:"pdf-command"` // pdftoppm 可执行文件
+	Lang       string `yaml:"lang"`
+	DPI        int    `yaml:"dpi"` // PDF 转图片的分辨率
+	Workers    int    `yaml:"workers"`
+	TimeoutSec int    `yaml:"timeout-sec"` // 单个附件的识别超时
+}
+
+// Search 档案检索索引配置
+type Search struct {
+	Engine    string `yaml:"engine"`     // mysql 或 bleve
+	BlevePath string `yaml:"bleve-path"` // Bleve 索引目录
+}
+
+// Subscription 查询订阅通知配置
+type Subscription struct {
+	IntervalMinute int `yaml:"interval-minute"` // 检查间隔，0 为不发送订阅通知
+}
+
+// Label 档案与档案盒标签的打印版式，单位为毫米
+type Label struct {
+	FontPath   string  `yaml:"font-path"` // 支持中文的 TTF 字体，为空时标签只打印编号等 ASCII 文字
+	Columns    int     `yaml:"columns"`
+	Rows       int     `yaml:"rows"`
+	Width      float64 `yaml:"width"`
+	Height     float64 `yaml:"height"`
+	MarginLeft float64 `yaml:"margin-left"`
+	MarginTop  float64 `yaml:"margin-top"`
+	Border     bool    `yaml:"border"` // 打印标签边框，便于在普通纸张上裁切
+}
+
+// GroupMapping 目录组到本地角色的映射
+type GroupMapping struct {
+	Group string `yaml:"group"`
+	Role  string `yaml:"role"`
+}
+
+// GroupPermission 目录组到本地 PermissionGroup 的映射
+type GroupPermission struct {
+	Group           string `yaml:"group"`
+	PermissionGroup string `yaml:"permission-group"`
+}
+
+// Mail 邮件发送配置，SMTPHost 为空时仅将邮件写入日志
+type Mail struct {
+	SMTPHost string `yaml:"smtp-host"`
+	SMTPPort int    `yaml:"smtp-port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// Validate 检查密码是否满足策略
+func (p PasswordPolicy) Validate(pwd string) error {
+	if len([]rune(pwd)) < p.MinLength {
+		return errors.New("密码长度不足")
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range pwd {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		return errors.New("密码需包含大写字母")
+	}
+	if p.RequireLower && !lower {
+		return errors.New("密码需包含小写字母")
+	}
+	if p.RequireDigit && !digit {
+		return errors.New("密码需包含数字")
+	}
+	if p.RequireSymbol && !symbol {
+		return errors.New("密码需包含特殊字符")
+	}
+	return nil
 }
 
 func FromYaml(dir string) (*Conf, error) {
@@ -19,10 +252,75 @@ func FromYaml(dir string) (*Conf, error) {
 	if err != nil {
 		panic(err)
 	}
-	config := Conf{}
+	config := Conf{
+		BcryptCost:       10,
+		InviteExpireHour: 72,
+		FileNoTemplate:   "{seq}",
+		PasswordPolicy: PasswordPolicy{
+			MinLength:    8,
+			RequireUpper: true,
+			RequireLower: true,
+			RequireDigit: true,
+		},
+		LoginProtection: LoginProtection{
+			MaxFailures:     5,
+			IPMaxFailures:   20,
+			WindowMinute:    15,
+			LockMinute:      30,
+			BaseDelaySecond: 1,
+			MaxDelaySecond:  60,
+		},
+		AuthBackends: []string{"local"},
+		LDAP: LDAP{
+			UserFilter:  "(&(objectClass=user)(|(sAMAccountName=%s)(mail=%s)))",
+			EmailAttr:   "mail",
+			NameAttr:    "displayName",
+			GroupAttr:   "memberOf",
+			DefaultRole: "viewer",
+		},
+		OIDC: OIDC{
+			Scopes:      []string{"openid", "email", "profile"},
+			LoginClaim:  "preferred_username",
+			EmailClaim:  "email",
+			NameClaim:   "name",
+			GroupsClaim: "groups",
+			DefaultRole: "viewer",
+		},
+		Storage: Storage{
+			Backend:   "local",
+			LocalRoot: "./data/attachments",
+			MaxSizeMB: 50,
+			S3:        S3{Region: "us-east-1"},
+
+			VerifyIntervalHour: 24,
+		},
+		OCR: OCR{
+			Engine:     "tesseract",
+			Command:    "tesseract",
+			PDFCommand: "pdftoppm",
+			Lang:       "chi_sim+eng",
+			DPI:        300,
+			Workers:    1,
+			TimeoutSec: 300,
+		},
+		Search: Search{
+			Engine:    "mysql",
+			BlevePath: "./data/search.bleve",
+		},
+		Subscription: Subscription{IntervalMinute: 60},
+		Label: Label{
+			Columns:   3,
+			Rows:      8,
+			Width:     70,
+			Height:    37,
+			MarginTop: 0.5,
+		},
+	}
 	err = yaml.Unmarshal(file, &config)
 	if err != nil {
 		panic(err)
 	}
+	config.Encryption.applyEnv()
+	config.Storage.applyEnv()
 	return &config, nil
 }
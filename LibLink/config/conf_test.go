@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	assert.NotEqual(t, nil, p.Validate("Ab1!"))
+	assert.NotEqual(t, nil, p.Validate("abcdefg1!"))
+	assert.NotEqual(t, nil, p.Validate("ABCDEFG1!"))
+	assert.NotEqual(t, nil, p.Validate("Abcdefgh!"))
+	assert.NotEqual(t, nil, p.Validate("Abcdefgh1"))
+	assert.Equal(t, nil, p.Validate("Abcdefg1!"))
+}
@@ -0,0 +1,70 @@
+// Package auth 登录认证后端，Login 按配置顺序依次尝试
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"liblink/config"
+	"liblink/internal/models/user"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCredentials 凭证错误或该后端不负责此账号，继续尝试下一个后端
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrAccountConflict 外部账号与其他来源的账号邮箱冲突
+	ErrAccountConflict = errors.New("该邮箱已被其他来源的账号占用")
+)
+
+// Authenticator 登录认证后端
+type Authenticator interface {
+	Name() string
+	// Authenticate 校验登录名与密码，成功时返回对应的本地用户
+	Authenticate(DB *gorm.DB, login, password string) (*user.User, error)
+}
+
+// FromConfig 根据配置创建认证后端
+func FromConfig(conf *config.Conf) ([]Authenticator, error) {
+	var authenticators []Authenticator
+	for _, name := range conf.AuthBackends {
+		switch strings.TrimSpace(name) {
+		case "local":
+			authenticators = append(authenticators, &LocalAuthenticator{})
+		case "ldap":
+			authenticators = append(authenticators, NewLDAPAuthenticator(conf.LDAP))
+		default:
+			return nil, fmt.Errorf("unknown auth backend: %s", name)
+		}
+	}
+	if len(authenticators) == 0 {
+		authenticators = append(authenticators, &LocalAuthenticator{})
+	}
+	return authenticators, nil
+}
+
+// Authenticate 依次尝试各个认证后端
+func Authenticate(authenticators []Authenticator, DB *gorm.DB, login, password string) (*user.User, error) {
+	for _, a := range authenticators {
+		u, err := a.Authenticate(DB, login, password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		return u, err
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// FindLoginUser 根据登录名查找本地用户，登录名可以是邮箱或外部账号的用户名
+func FindLoginUser(DB *gorm.DB, login string) (*user.User, error) {
+	var u user.User
+	err := DB.Where("email = ?", login).First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && !strings.Contains(login, "@") {
+		err = DB.Where("username = ? AND source <> ?", login, user.SourceLocal).First(&u).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
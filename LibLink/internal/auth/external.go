@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"liblink/config"
+	"liblink/internal/models/user"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ExternalUser 外部认证系统（LDAP、OIDC）返回的用户信息
+type ExternalUser struct {
+	ID              string // 外部账号标识，LDAP 为 DN，OIDC 为 sub
+	Login           string
+	Email           string
+	Name            string
+	Groups          []string
+	Role            string // 根据外部组映射得到的角色
+	PermissionGroup string // 根据外部组映射得到的 PermissionGroup
+}
+
+// mapGroups 将外部组映射为本地角色与 PermissionGroup，角色取第一个匹配项，用户组合并全部匹配项
+func mapGroups(groups []string, defaultRole string, roles []config.GroupMapping, perms []config.GroupPermission) (string, string) {
+	role := defaultRole
+	for _, m := range roles {
+		if containsFold(groups, m.Group) {
+			role = m.Role
+			break
+		}
+	}
+
+	var permissionGroups []string
+	for _, m := range perms {
+		if containsFold(groups, m.Group) {
+			permissionGroups = append(permissionGroups, m.PermissionGroup)
+		}
+	}
+	return role, strings.Join(permissionGroups, ",")
+}
+
+// provisionExternal 创建或同步外部用户对应的本地用户，姓名、角色与用户组以外部系统为准
+// 邮箱已被其他来源的账号占用时拒绝登录，防止通过外部账号接管本地账号
+func provisionExternal(DB *gorm.DB, source string, ext *ExternalUser) (*user.User, error) {
+	// 外部系统未提供姓名时使用登录名
+	name := strings.TrimSpace(ext.Name)
+	if name == "" {
+		name = ext.Login
+	}
+
+	var u user.User
+	err := DB.Where("email = ?", ext.Email).First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		u = user.User{
+			Username:        name,
+			Email:           ext.Email,
+			Role:            ext.Role,
+			PermissionGroup: ext.PermissionGroup,
+			Status:          user.StatusActive,
+			Source:          source,
+			ExternalID:      ext.ID,
+		}
+		if err := DB.Create(&u).Error; err != nil {
+			return nil, err
+		}
+		return &u, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Source != source {
+		return nil, ErrAccountConflict
+	}
+
+	updates := map[string]interface{}{
+		"username":         name,
+		"role":             ext.Role,
+		"permission_group": ext.PermissionGroup,
+		"external_id":      ext.ID,
+	}
+	if err := DB.Model(&u).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"liblink/config"
+	"liblink/internal/models/user"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// LDAPAuthenticator 通过 LDAP 绑定校验密码，首次登录时自动创建本地用户
+type LDAPAuthenticator struct {
+	conf config.LDAP
+}
+
+func NewLDAPAuthenticator(conf config.LDAP) *LDAPAuthenticator {
+	return &LDAPAuthenticator{conf: conf}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) Authenticate(DB *gorm.DB, login, password string) (*user.User, error) {
+	dirUser, err := a.Lookup(login, password)
+	if err != nil {
+		return nil, err
+	}
+	return a.Provision(DB, dirUser)
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.conf.InsecureTLS}
+	conn, err := ldap.DialURL(a.conf.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if a.conf.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Lookup 使用服务账号查找用户 DN，再以用户身份绑定校验密码
+func (a *LDAPAuthenticator) Lookup(login, password string) (*ExternalUser, error) {
+	// 空密码会被部分目录当作匿名绑定而成功，必须拒绝
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.conf.BindDN != "" {
+		if err := conn.Bind(a.conf.BindDN, a.conf.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind: %w", err)
+		}
+	}
+
+	escaped := ldap.EscapeFilter(login)
+	filter := strings.ReplaceAll(a.conf.UserFilter, "%s", escaped)
+	req := ldap.NewSearchRequest(
+		a.conf.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		filter,
+		[]string{a.conf.EmailAttr, a.conf.NameAttr, a.conf.GroupAttr},
+		nil,
+	)
+	result, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap search: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind: %w", err)
+	}
+
+	dirUser := &ExternalUser{
+		ID:     entry.DN,
+		Login:  login,
+		Email:  entry.GetAttributeValue(a.conf.EmailAttr),
+		Name:   entry.GetAttributeValue(a.conf.NameAttr),
+		Groups: entry.GetAttributeValues(a.conf.GroupAttr),
+	}
+	if dirUser.Email == "" {
+		return nil, errors.New("目录账号缺少邮箱属性")
+	}
+	dirUser.Role, dirUser.PermissionGroup = a.MapGroups(dirUser.Groups)
+	return dirUser, nil
+}
+
+// MapGroups 将目录组映射为本地角色与 PermissionGroup
+func (a *LDAPAuthenticator) MapGroups(groups []string) (role string, permissionGroup string) {
+	return mapGroups(groups, a.conf.DefaultRole, a.conf.GroupRoles, a.conf.GroupPermissions)
+}
+
+// Provision 创建或同步目录用户对应的本地用户，角色与用户组以目录为准
+func (a *LDAPAuthenticator) Provision(DB *gorm.DB, dirUser *ExternalUser) (*user.User, error) {
+	return provisionExternal(DB, user.SourceLDAP, dirUser)
+}
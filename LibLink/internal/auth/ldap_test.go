@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"liblink/config"
+	"net"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-playground/assert/v2"
+)
+
+type testEntry struct {
+	dn       string
+	uid      string
+	mail     string
+	password string
+	groups   []string
+}
+
+// testLDAPServer 仅实现 Bind 与 Search 的进程内 LDAP 服务
+type testLDAPServer struct {
+	listener net.Listener
+	entries  []testEntry
+}
+
+func newTestLDAPServer(t *testing.T, entries []testEntry) *testLDAPServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testLDAPServer{listener: l, entries: entries}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			name := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := ldap.LDAPResultInvalidCredentials
+			if s.checkBind(name, password) {
+				code = ldap.LDAPResultSuccess
+			}
+			conn.Write(result(id, ldap.ApplicationBindResponse, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, e := range s.entries {
+				if strings.Contains(filter, "="+e.uid+")") || strings.Contains(filter, "="+e.mail+")") {
+					conn.Write(entryPacket(id, e).Bytes())
+				}
+			}
+			conn.Write(result(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *testLDAPServer) checkBind(dn, password string) bool {
+	if dn == "cn=svc,dc=test" && password == "svc" {
+		return true
+	}
+	for _, e := range s.entries {
+		if e.dn == dn && e.password == password && password != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func envelope(id int64) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	return p
+}
+
+func result(id int64, tag ber.Tag, code int) *ber.Packet {
+	p := envelope(id)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	p.AppendChild(op)
+	return p
+}
+
+func entryPacket(id int64, e testEntry) *ber.Packet {
+	p := envelope(id)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range map[string][]string{"mail": {e.mail}, "displayName": {e.uid}, "memberOf": e.groups} {
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "val"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	p.AppendChild(op)
+	return p
+}
+
+func newTestAuthenticator(url string) *LDAPAuthenticator {
+	return NewLDAPAuthenticator(config.LDAP{
+		URL:          url,
+		BindDN:       "cn=svc,dc=test",
+		BindPassword: "svc",
+		BaseDN:       "dc=test",
+		UserFilter:   "(&(objectClass=user)(|(sAMAccountName=%s)(mail=%s)))",
+		EmailAttr:    "mail",
+		NameAttr:     "displayName",
+		GroupAttr:    "memberOf",
+		DefaultRole:  "viewer",
+		GroupRoles: []config.GroupMapping{
+			{Group: "cn=archive-admins,dc=test", Role: "admin"},
+			{Group: "cn=archive-clerks,dc=test", Role: "clerk"},
+		},
+		GroupPermissions: []config.GroupPermission{
+			{Group: "cn=loan,dc=test", PermissionGroup: "loan"},
+			{Group: "cn=hr,dc=test", PermissionGroup: "hr"},
+		},
+	})
+}
+
+func TestLDAPLookup(t *testing.T) {
+	server := newTestLDAPServer(t, []testEntry{
+		{
+			dn:       "cn=alice,dc=test",
+			uid:      "alice",
+			mail:     "alice@bank.test",
+			password: "secret",
+			groups:   []string{"CN=Archive-Clerks,DC=test", "cn=loan,dc=test", "cn=hr,dc=test"},
+		},
+		{dn: "cn=bob,dc=test", uid: "bob", mail: "bob@bank.test", password: "secret"},
+	})
+	a := newTestAuthenticator(server.URL())
+
+	u, err := a.Lookup("alice", "secret")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "cn=alice,dc=test", u.ID)
+	assert.Equal(t, "alice@bank.test", u.Email)
+	assert.Equal(t, "clerk", u.Role)
+	assert.Equal(t, "loan,hr", u.PermissionGroup)
+
+	u, err = a.Lookup("bob@bank.test", "secret")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "viewer", u.Role)
+	assert.Equal(t, "", u.PermissionGroup)
+
+	for _, c := range []struct{ login, password string }{
+		{"alice", "wrong"},
+		{"alice", ""},
+		{"carol", "secret"},
+		{"*", "secret"},
+	} {
+		_, err = a.Lookup(c.login, c.password)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidCredentials))
+	}
+}
+
+func TestLDAPUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	a := newTestAuthenticator(fmt.Sprintf("ldap://%s", addr))
+	_, err = a.Lookup("alice", "secret")
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, false, errors.Is(err, ErrInvalidCredentials))
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"liblink/internal/models/user"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// LocalAuthenticator 校验保存在本系统中的 bcrypt 密码
+type LocalAuthenticator struct{}
+
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+func (a *LocalAuthenticator) Authenticate(DB *gorm.DB, login, password string) (*user.User, error) {
+	var u user.User
+	if err := DB.Where("email = ? AND source = ?", login, user.SourceLocal).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if !CheckPassword(&u, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return &u, nil
+}
+
+// CheckPassword 校验本地用户密码
+func CheckPassword(u *user.User, password string) bool {
+	if u.Password == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
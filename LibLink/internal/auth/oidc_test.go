@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"liblink/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testIssuer 本地模拟的 OIDC 提供方
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// 授权码对应的 code_challenge 与 ID Token 声明
+	codes map[string]testGrant
+}
+
+type testGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{key: key, codes: make(map[string]testGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.server.URL,
+			"authorization_endpoint": iss.server.URL + "/authorize",
+			"token_endpoint":         iss.server.URL + "/token",
+			"jwks_uri":               iss.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		grant, ok := iss.codes[r.PostForm.Get("code")]
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": iss.sign(t, grant.claims)})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	s, err := token.SignedString(iss.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (iss *testIssuer) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                iss.server.URL,
+		"aud":                []interface{}{"liblink"},
+		"sub":                "u-1001",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              nonce,
+		"email":              "alice@example.com",
+		"preferred_username": "alice",
+		"groups":             []interface{}{"liblink-admins", "liblink-loan"},
+	}
+}
+
+func newTestProvider(issuer string) *OIDCProvider {
+	return NewOIDCProvider(config.OIDC{
+		Issuer:      issuer,
+		ClientID:    "liblink",
+		RedirectURL: "http://localhost/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		LoginClaim:  "preferred_username",
+		EmailClaim:  "email",
+		GroupsClaim: "groups",
+		DefaultRole: "viewer",
+		GroupRoles:  []config.GroupMapping{{Group: "liblink-admins", Role: "admin"}},
+		GroupPermissions: []config.GroupPermission{
+			{Group: "liblink-loan", PermissionGroup: "loan"},
+		},
+	})
+}
+
+func TestOIDCCodeFlow(t *testing.T) {
+	iss := newTestIssuer(t)
+	p := newTestProvider(iss.server.URL)
+	ctx := context.Background()
+
+	verifier, challenge := NewPKCE()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != "S256" || q.Get("state") != "state-1" {
+		t.Fatalf("unexpected auth url: %s", authURL)
+	}
+	iss.codes["code-1"] = testGrant{challenge: q.Get("code_challenge"), claims: iss.claims(q.Get("nonce"))}
+
+	if _, err := p.Exchange(ctx, "code-1", "wrong-verifier"); err == nil {
+		t.Fatal("exchange with wrong verifier should fail")
+	}
+
+	rawIDToken, err := p.Exchange(ctx, "code-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := p.MapClaims(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.ID != "u-1001" || ext.Login != "alice" || ext.Email != "alice@example.com" {
+		t.Fatalf("unexpected user: %+v", ext)
+	}
+	if ext.Role != "admin" || ext.PermissionGroup != "loan" {
+		t.Fatalf("unexpected mapping: role=%s group=%s", ext.Role, ext.PermissionGroup)
+	}
+}
+
+func TestOIDCVerifyIDTokenRejects(t *testing.T) {
+	iss := newTestIssuer(t)
+	p := newTestProvider(iss.server.URL)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func() string{
+		"wrong nonce": func() string {
+			return iss.sign(t, iss.claims("other"))
+		},
+		"wrong audience": func() string {
+			c := iss.claims("nonce-1")
+			c["aud"] = "someone-else"
+			return iss.sign(t, c)
+		},
+		"wrong issuer": func() string {
+			c := iss.claims("nonce-1")
+			c["iss"] = "https://evil.example.com"
+			return iss.sign(t, c)
+		},
+		"expired": func() string {
+			c := iss.claims("nonce-1")
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+			return iss.sign(t, c)
+		},
+		"wrong key": func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, iss.claims("nonce-1"))
+			token.Header["kid"] = "test"
+			s, _ := token.SignedString(otherKey)
+			return s
+		},
+		"hmac": func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, iss.claims("nonce-1"))
+			token.Header["kid"] = "test"
+			s, _ := token.SignedString([]byte("secret"))
+			return s
+		},
+	}
+	for name, makeToken := range cases {
+		if _, err := p.VerifyIDToken(ctx, makeToken(), "nonce-1"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestOIDCMapClaimsUnverifiedEmail(t *testing.T) {
+	p := newTestProvider("https://sso.example.com")
+	claims := jwt.MapClaims{"sub": "u-1", "email": "bob@example.com", "email_verified": false}
+	if _, err := p.MapClaims(claims); err == nil {
+		t.Fatal("unverified email should be rejected")
+	}
+}
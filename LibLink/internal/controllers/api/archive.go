@@ -2,15 +2,18 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"liblink/internal/controllers/message"
 	"liblink/internal/global"
 	"liblink/internal/middleware"
 	"liblink/internal/models/archive"
+	"liblink/internal/models/branch"
+	"liblink/internal/models/location"
 	"liblink/internal/models/user"
+	"liblink/pkg/fieldcrypt"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/xuri/excelize/v2"
@@ -61,83 +64,96 @@ func GetArchiveByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "获取档案成功",
-		"data":    arc,
-	})
-}
-
-// CreateArchive 创建档案(文件夹层级)
-func CreateArchive(c *gin.Context) {
-	// 获取当前用户信息
-	email := middleware.GetEmail(c)
-
-	var currentUser user.User
-	if err := global.DB.Where("email = ?", email).First(&currentUser).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
-			return
-		}
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
 		return
 	}
+	if !access.Allows(arc.InstNo) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权访问其他网点的档案"})
+		return
+	}
 
-	// 绑定请求参数
-	var req struct {
-		FileNo      string `json:"file_no" binding:"required"`
-		Title       string `json:"title" binding:"required"`
-		ContractNo  string `json:"contract_no"`
-		InstNo      string `json:"inst_no"`
-		ArcType     string `json:"arc_type"`
-		BorrowState string `json:"borrow_state"`
-		FolderID    uint   `json:"folder_id" binding:"required"`
+	paths, err := location.Paths(global.DB, []uint{arc.LocationID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "获取档案成功",
+		"data":     shapeArchive(c, &arc),
+		"location": paths[arc.LocationID],
+	})
+}
+
+// archiveQuery 档案列表与检索共用的筛选、排序、分页参数
+type archiveQuery struct {
+	message.RequestMsg
+	archive.Query
+	Sort  string `json:"sort" form:"sort"`   // 见 archiveSortColumns
+	Order string `json:"order" form:"order"` // asc、desc
+}
+
+// bindArchiveQuery 解析查询参数，自定义字段条件使用 extra[key]、extra_min[key]、extra_max[key]
+func bindArchiveQuery(c *gin.Context, request *archiveQuery) bool {
+	if err := c.ShouldBindQuery(request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
-		return
+		return false
 	}
+	request.Extra = archive.ExtraFilter{
+		Eq:  c.QueryMap("extra"),
+		Min: c.QueryMap("extra_min"),
+		Max: c.QueryMap("extra_max"),
+	}
+	return true
+}
 
-	// 校验文件夹权限
-	var folder archive.Folder
-	if err := global.DB.First(&folder, req.FolderID).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"message": "文件夹不存在"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+// archiveSortColumns 可排序的列，姓名、身份证号加密存储不支持排序
+var archiveSortColumns = map[string]string{
+	"":             "id",
+	"id":           "id",
+	"file_no":      "file_no",
+	"contract_no":  "contract_no",
+	"title":        "title",
+	"inst_no":      "inst_no",
+	"manager":      "manager",
+	"amount":       "amount",
+	"arc_type":     "arc_type",
+	"borrow_state": "borrow_state",
+	"storage_date": "storage_date",
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+}
+
+// GetArchives 获取当前用户的档案列表
+func GetArchives(c *gin.Context) {
+	var request archiveQuery
+	if !bindArchiveQuery(c, &request) {
 		return
 	}
+	listArchives(c, &request)
+}
 
-	if !archive.CheckPermission(folder.GroupPermission, currentUser.PermissionGroup) {
-		c.JSON(http.StatusForbidden, gin.H{"message": "无权在该文件夹下创建档案"})
+// SearchArchives 检索档案，q 在标题、姓名、合同编号、客户经理、档案编号中匹配，多个词需全部匹配
+// 其余筛选、排序、分页参数与档案列表相同
+func SearchArchives(c *gin.Context) {
+	var request archiveQuery
+	if !bindArchiveQuery(c, &request) {
 		return
 	}
 
-	// 创建档案
-	newArc, err := archive.CreateArchive(
-		global.DB,
-		req.FileNo,
-		req.Title,
-		req.ContractNo,
-		req.InstNo,
-		req.ArcType,
-		req.BorrowState,
-		currentUser.Email,
-		req.FolderID,
-	)
+	scope, err := global.Search.Scope(c.Request.Context(), c.Query("q"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "创建档案失败", "error": err.Error()})
+		global.Logger.Error("search archives error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "检索失败"})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "档案创建成功",
-		"data":    newArc,
-	})
+	listArchives(c, &request, scope)
 }
 
-// GetArchives 获取当前用户的档案列表
-func GetArchives(c *gin.Context) {
+// listArchives 按筛选条件分页查询当前用户有权访问的档案
+func listArchives(c *gin.Context, request *archiveQuery, scopes ...func(db *gorm.DB) *gorm.DB) {
 	// 获取当前用户信息
 	email := middleware.GetEmail(c)
 
@@ -151,70 +167,75 @@ func GetArchives(c *gin.Context) {
 		return
 	}
 
-	type archRequest struct {
-		message.RequestMsg
-		ContractNo  string `json:"contract_no" form:"contract_no"`
-		ArcType     string `json:"arc_type" form:"arc_type"`
-		InstNo      string `json:"inst_no" form:"inst_no"`
-		BorrowState string `json:"borrow_state" form:"borrow_state"`
-	}
-
-	var request archRequest
-	if err := c.ShouldBindQuery(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
 		return
 	}
 
-	db := global.DB.Model(&archive.Archive{}).Where("group_permission = ?", currentUser.PermissionGroup)
+	db := global.DB.Model(&archive.Archive{}).Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope()).Scopes(scopes...)
 
-	// 筛选字段
-	if request.ContractNo != "" {
-		db = db.Where("contract_no LIKE ?", "%"+request.ContractNo+"%")
+	v := &archive.Validator{}
+	filter, err := request.Query.Scope(global.DB, v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
 	}
-
-	if request.ArcType != "" {
-		db = db.Where("arc_type = ?", request.ArcType)
+	if !v.Valid() {
+		validationFailed(c, v.Errors)
+		return
 	}
+	db = db.Scopes(filter)
 
-	if request.InstNo != "" {
-		db = db.Where("inst_no = ?", request.InstNo)
+	sortColumn, ok := archiveSortColumns[request.Sort]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不支持的排序字段"})
+		return
 	}
+	desc := request.Order != "asc"
 
-	if request.BorrowState != "" {
-		db = db.Where("borrow_state = ?", request.BorrowState)
+	// 查询档案列表
+	var archives []archive.Archive
+	page, ok := paginate(c, &request.RequestMsg, db, sortColumn, desc, &archives)
+	if !ok {
+		return
 	}
 
-	// 自动分页
-	if request.Page <= 0 {
-		request.Page = 1
+	response := gin.H{
+		"message": "获取档案列表成功",
+		"data":    shapeArchives(c, archives),
 	}
+	page.Fill(response)
 
-	if request.PageSize <= 0 {
-		request.PageSize = 10
+	// 档案所在位置的完整路径，按位置ID返回
+	var locationIDs []uint
+	for _, a := range archives {
+		if a.LocationID != 0 {
+			locationIDs = append(locationIDs, a.LocationID)
+		}
 	}
-
-	var total int64
-	if err := db.Count(&total).Error; err != nil {
+	paths, err := location.Paths(global.DB, locationIDs)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
 		return
 	}
+	response["locations"] = paths
 
-	db = db.Offset((request.Page - 1) * request.PageSize).Limit(request.PageSize)
-
-	// 查询档案列表
-	var archives []archive.Archive
-	if err := db.Find(&archives).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
-		return
+	// 全文检索时返回命中的附件与高亮片段，按档案ID分组
+	if request.Text != "" {
+		ids := make([]uint, len(archives))
+		for i, a := range archives {
+			ids[i] = a.ID
+		}
+		hits, err := archive.TextHits(global.DB, ids, request.Text)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		response["highlights"] = hits
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "获取档案列表成功",
-		"page":      request.Page,
-		"page_size": request.PageSize,
-		"total":     total,
-		"data":      archives,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 // AddArchive 新增档案(不管文件夹层级)
@@ -238,8 +259,47 @@ func AddArchive(c *gin.Context) {
 		return
 	}
 
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	// 未指定网点时默认为用户唯一的所属网点
+	if userInstNos := branch.SplitInstNos(currentUser.InstNos); newArchive.InstNo == "" && len(userInstNos) == 1 {
+		newArchive.InstNo = userInstNos[0]
+	}
+	if !access.Allows(newArchive.InstNo) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权在其他网点创建档案"})
+		return
+	}
+
+	types, err := archive.LoadArchiveTypes(global.DB, newArchive.ArcType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	v := &archive.Validator{}
+	newArchive.Validate(v)
+	arcType := v.ArcType(types, newArchive.ArcType)
+	if arcType != nil {
+		newArchive.Extra = v.Extra(arcType, newArchive.Extra)
+	}
+	if err := v.ContractNoUnique(global.DB, newArchive.ContractNo, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !v.Valid() {
+		validationFailed(c, v.Errors)
+		return
+	}
+
 	// 后端生成字段
-	newArchive.FileNo = archive.GetArcTypeFileNo(global.DB, newArchive.ArcType)
+	newArchive.FileNo, err = archive.NextFileNo(global.DB, fileNoTemplate(arcType), newArchive.ArcType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成档案编号失败", "error": err.Error()})
+		return
+	}
 	newArchive.GroupPermission = currentUser.PermissionGroup
 	newArchive.CreatorID = currentUser.Email
 
@@ -250,7 +310,7 @@ func AddArchive(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "档案创建成功",
-		"data":    newArchive,
+		"data":    shapeArchive(c, newArchive),
 	})
 }
 
@@ -299,7 +359,34 @@ func BatchImportArchives(c *gin.Context) {
 		return
 	}
 
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	// 预先加载文件中出现的档案类型
+	var codes []string
+	for i, row := range rows {
+		if i > 0 && len(row) > 0 {
+			codes = append(codes, row[0])
+		}
+	}
+	types, err := archive.LoadArchiveTypes(global.DB, codes...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	// 第 9 列起为自定义字段，表头为字段键名或名称
+	var header []string
+	if len(rows) > 0 {
+		header = rows[0]
+	}
+
 	var archives []archive.Archive
+	var denied []string
+	var invalid archive.ValidationErrors
+	seen := make(map[string]int)
 	for i, row := range rows {
 		if i == 0 {
 			// 第一行是表头
@@ -309,36 +396,108 @@ func BatchImportArchives(c *gin.Context) {
 		if len(row) < 6 {
 			continue
 		}
+		// 末尾的空单元格不会出现在 row 中
+		cell := func(col int) string {
+			if col < len(row) {
+				return row[col]
+			}
+			return ""
+		}
+
+		if !access.Allows(row[4]) {
+			denied = append(denied, "第"+strconv.Itoa(i+1)+"行网点编号 "+row[4]+" 不在权限范围内")
+			continue
+		}
 
 		// 档案类型 	合同编号	姓名	身份证号	网点编号	客户经理	合同金额 	存档日期
 		a := archive.Archive{
-			FileNo:          archive.GetArcTypeFileNo(global.DB, row[0]),
 			ArcType:         row[0],
 			ContractNo:      row[1],
-			Name:            row[2],
-			IDCard:          row[3],
+			Name:            fieldcrypt.String(row[2]),
+			IDCard:          fieldcrypt.String(row[3]),
 			InstNo:          row[4],
 			Manager:         row[5],
-			Amount:          row[6],
 			GroupPermission: currentUser.PermissionGroup,
 			CreatorID:       currentUser.Email,
 			BorrowState:     "0", // 默认未借阅
 		}
-		// 存档日期如果为空，则默认为今日
-		if len(row) > 7 && row[7] != "" {
-			a.StorageDate = row[7]
+		// 无法解析的原文保留到校验时报告
+		a.Amount, _ = archive.ParseMoney(cell(6))
+		a.StorageDate, _ = archive.ParseDateValue(cell(7))
+
+		v := &archive.Validator{Row: i + 1}
+		a.Validate(v)
+		if arcType := v.ArcType(types, a.ArcType); arcType != nil {
+			extra := archive.Metadata{}
+			for col := 8; col < len(header); col++ {
+				if d, ok := arcType.Fields.Field(header[col]); ok && cell(col) != "" {
+					extra[d.Key] = cell(col)
+				}
+			}
+			a.Extra = v.Extra(arcType, extra)
+		}
+		if _, ok := seen[a.ContractNo]; ok && a.ContractNo != "" {
+			v.Errors = append(v.Errors, archive.ValidationError{Row: i + 1, Field: "contract_no", Code: archive.ErrCodeContractNoRepeat, Value: a.ContractNo})
 		} else {
-			a.StorageDate = time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+			seen[a.ContractNo] = i + 1
+		}
+		invalid = append(invalid, v.Errors...)
+
+		// 存档日期如果为空，则默认为今日
+		if !a.StorageDate.Valid {
+			a.StorageDate = archive.Today()
 		}
 
 		archives = append(archives, a)
 	}
 
+	if len(denied) > 0 {
+		c.JSON(http.StatusForbidden, gin.H{"message": "存在无权导入的网点数据", "detail": denied})
+		return
+	}
+
+	// 校验合同编号是否与已有档案重复
+	contractNos := make([]string, 0, len(archives))
+	for _, a := range archives {
+		contractNos = append(contractNos, a.ContractNo)
+	}
+	existing, err := archive.ContractNosExisting(global.DB, contractNos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	for _, a := range archives {
+		if existing[a.ContractNo] {
+			invalid = append(invalid, archive.ValidationError{Row: seen[a.ContractNo], Field: "contract_no", Code: archive.ErrCodeContractNoExists, Value: a.ContractNo})
+		}
+	}
+
+	if len(invalid) > 0 {
+		validationFailed(c, invalid)
+		return
+	}
+
 	if len(archives) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Excel中没有有效数据"})
 		return
 	}
 
+	// 校验通过后按档案类型批量分配编号
+	byType := make(map[string][]int)
+	for i, a := range archives {
+		byType[a.ArcType] = append(byType[a.ArcType], i)
+	}
+	for arcType, indexes := range byType {
+		fileNos, err := archive.NextFileNos(global.DB, fileNoTemplate(types[arcType]), arcType, len(indexes))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "生成档案编号失败", "error": err.Error()})
+			return
+		}
+		for j, i := range indexes {
+			archives[i].FileNo = fileNos[j]
+		}
+	}
+
 	// 批量插入
 	if err := global.DB.Create(&archives).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "批量导入失败", "error": err.Error()})
@@ -352,15 +511,23 @@ func BatchImportArchives(c *gin.Context) {
 }
 
 func BorrowArchive(c *gin.Context) {
-	// TODO: 鉴权，该用户是否有对应档案操作权限
 	contractNo := c.Query("contract_no")
 	if contractNo == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少合同编号"})
 		return
 	}
 
+	access, ok := currentBranchAccess(c)
+	if !ok {
+		return
+	}
+
 	ctx := context.WithValue(context.Background(), archive.ArchiveOperateUserID, middleware.GetEmail(c))
-	if err := operateArchive(contractNo, ctx, "1"); err != nil {
+	if err := operateArchive(contractNo, ctx, "1", access); err != nil {
+		if errors.Is(err, errBranchDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "借阅档案失败",
 			"error":   err.Error(),
@@ -371,14 +538,23 @@ func BorrowArchive(c *gin.Context) {
 }
 
 func ReturnArchive(c *gin.Context) {
-	// TODO: 鉴权，该用户是否有对应档案操作权限
 	contractNo := c.Query("contract_no")
 	if contractNo == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少合同编号"})
 		return
 	}
+
+	access, ok := currentBranchAccess(c)
+	if !ok {
+		return
+	}
+
 	ctx := context.WithValue(context.Background(), archive.ArchiveOperateUserID, middleware.GetEmail(c))
-	if err := operateArchive(contractNo, ctx, "0"); err != nil {
+	if err := operateArchive(contractNo, ctx, "0", access); err != nil {
+		if errors.Is(err, errBranchDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "归还档案失败",
 			"error":   err.Error(),
@@ -433,6 +609,12 @@ func BatchOperateArchives(c *gin.Context) {
 		return
 	}
 
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
 	// 批量解析借阅
 	var message []string
 	for i, row := range rows {
@@ -446,7 +628,7 @@ func BatchOperateArchives(c *gin.Context) {
 		}
 
 		ctx := context.WithValue(context.Background(), archive.ArchiveOperateUserID, currentUser.Email)
-		err := operateArchive(row[0], ctx, row[1])
+		err := operateArchive(row[0], ctx, row[1], access)
 		if err != nil {
 			message = append(message, "第"+strconv.Itoa(i+1)+"行操作失败: "+err.Error())
 		}
@@ -458,19 +640,44 @@ func BatchOperateArchives(c *gin.Context) {
 	})
 }
 
-func operateArchive(contractNo string, ctx context.Context, status string) error {
+// errBranchDenied 操作其他网点的档案
+var errBranchDenied = errors.New("无权操作其他网点的档案")
+
+func operateArchive(contractNo string, ctx context.Context, status string, access *branchAccess) error {
+	return operateArchiveWith(global.DB, contractNo, ctx, status, access)
+}
+
+// operateArchiveWith 在指定的连接或事务中更新借阅状态
+func operateArchiveWith(db *gorm.DB, contractNo string, ctx context.Context, status string, access *branchAccess) error {
 	var arch archive.Archive
-	if err := global.DB.Where("contract_no = ?", contractNo).First(&arch).Error; err != nil {
+	if err := db.Where("contract_no = ?", contractNo).First(&arch).Error; err != nil {
 		return err
 	}
+	return setBorrowState(db, &arch, ctx, status, access)
+}
+
+// operateArchiveByID 按档案ID更新借阅状态，用于已解析出档案的批量借还
+func operateArchiveByID(db *gorm.DB, id uint, ctx context.Context, status string, access *branchAccess) error {
+	var arch archive.Archive
+	if err := db.First(&arch, id).Error; err != nil {
+		return err
+	}
+	return setBorrowState(db, &arch, ctx, status, access)
+}
+
+// setBorrowState 校验网点权限与当前状态后更新借阅状态
+func setBorrowState(db *gorm.DB, arch *archive.Archive, ctx context.Context, status string, access *branchAccess) error {
+	if !access.Allows(arch.InstNo) {
+		return errBranchDenied
+	}
 
 	if arch.BorrowState == status {
 		return fmt.Errorf("档案状态已是 %s", status)
 	}
 
 	arch.BorrowState = status
-	if err := global.DB.WithContext(ctx).
-		Model(&arch).
+	if err := db.WithContext(ctx).
+		Model(arch).
 		Update("borrow_state", status).Error; err != nil {
 		return err
 	}
@@ -512,30 +719,85 @@ func UpdateArchive(c *gin.Context) {
 
 	// 绑定请求参数
 	var req struct {
-		Title       string `json:"title"`
-		Name        string `json:"name"`
-		IDCard      string `json:"id_card"`
-		InstNo      string `json:"inst_no"`
-		Manager     string `json:"manager"`
-		Amount      string `json:"amount"`
-		ArcType     string `json:"arc_type"`
-		StorageDate string `json:"storage_date"`
+		Title       string           `json:"title"`
+		Name        string           `json:"name"`
+		IDCard      string           `json:"id_card"`
+		InstNo      string           `json:"inst_no"`
+		Manager     string           `json:"manager"`
+		Amount      string           `json:"amount"`
+		ArcType     string           `json:"arc_type"`
+		StorageDate string           `json:"storage_date"`
+		Extra       archive.Metadata `json:"extra"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
 		return
 	}
 
+	access, err := getBranchAccess(&currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !access.Allows(arc.InstNo) || !access.Allows(req.InstNo) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权修改其他网点的档案"})
+		return
+	}
+
+	// 无敏感信息查看权限的用户拿到的是脱敏数据，原样提交时保留原值
+	req.Name = unmaskedValue(req.Name, string(arc.Name), archive.MaskName)
+	req.IDCard = unmaskedValue(req.IDCard, string(arc.IDCard), archive.MaskIDCard)
+	req.Amount = unmaskedValue(req.Amount, arc.Amount.String(), archive.MaskAmount)
+
+	// 身份证号只校验有修改的情况，未修改的历史数据保持原样
+	v := &archive.Validator{}
+	if req.IDCard != string(arc.IDCard) {
+		req.IDCard = v.IDCard("id_card", req.IDCard)
+	}
+	amount := v.Amount("amount", req.Amount)
+	storageDate := v.Date("storage_date", req.StorageDate)
+
+	// 修改档案类型时校验新类型，未修改时允许沿用已停用的类型
+	types, err := archive.LoadArchiveTypes(global.DB, req.ArcType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	arcType := types[req.ArcType]
+	if req.ArcType != arc.ArcType {
+		arcType = v.ArcType(types, req.ArcType)
+	}
+	if arcType == nil {
+		arcType = &archive.ArchiveType{}
+	}
+	extra := v.Extra(arcType, req.Extra)
+	if !v.Valid() {
+		validationFailed(c, v.Errors)
+		return
+	}
+
 	// 更新数据
 	updates := map[string]interface{}{
-		"title":        req.Title,
-		"name":         req.Name,
-		"id_card":      req.IDCard,
-		"inst_no":      req.InstNo,
-		"manager":      req.Manager,
-		"amount":       req.Amount,
-		"arc_type":     req.ArcType,
-		"storage_date": req.StorageDate,
+		"title":         req.Title,
+		"name":          fieldcrypt.String(req.Name),
+		"id_card":       fieldcrypt.String(req.IDCard),
+		"id_card_index": archive.IDCardIndex(req.IDCard),
+		"inst_no":       req.InstNo,
+		"manager":       req.Manager,
+		"amount":        amount,
+		"arc_type":      req.ArcType,
+		"storage_date":  storageDate,
+		"extra":         extra,
+	}
+
+	// 档案编号按类型分别计数，修改类型时从新类型的序列重新分配，避免与新类型下的编号冲突
+	if req.ArcType != arc.ArcType {
+		fileNo, err := archive.NextFileNo(global.DB, fileNoTemplate(arcType), req.ArcType)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "生成档案编号失败", "error": err.Error()})
+			return
+		}
+		updates["file_no"] = fileNo
 	}
 
 	if err := global.DB.Model(&arc).Updates(updates).Error; err != nil {
@@ -545,6 +807,113 @@ func UpdateArchive(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "档案更新成功",
-		"data":    arc,
+		"data":    shapeArchive(c, &arc),
+	})
+}
+
+// ArchiveRecords 档案借阅历史
+func ArchiveRecords(c *gin.Context) {
+	type recordRequest struct {
+		message.RequestMsg
+		ContractNo string `json:"contract_no" form:"contract_no"`
+		LoanID     uint   `json:"loan_id" form:"loan_id"`
+	}
+
+	var request recordRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	// 只返回当前用户有权访问的档案的借阅记录
+	accessible := global.DB.Model(&archive.Archive{}).Select("contract_no").
+		Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope())
+	db := global.DB.Model(&archive.ArchiveRecord{}).Where("contract_no IN (?)", accessible)
+	if request.ContractNo != "" {
+		db = db.Where("contract_no = ?", request.ContractNo)
+	}
+	if request.LoanID != 0 {
+		db = db.Where("loan_id = ?", request.LoanID)
+	}
+
+	var records []archive.ArchiveRecord
+	page, ok := paginate(c, &request.RequestMsg, db, "id", true, &records)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"message": "获取借阅历史成功",
+		"data":    records,
+	}
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
+}
+
+// ArchivesSummary 按网点统计档案数量与借出数量
+func ArchivesSummary(c *gin.Context) {
+	access, ok := currentBranchAccess(c)
+	if !ok {
+		return
+	}
+
+	type instSummary struct {
+		InstNo      string        `json:"inst_no"`
+		Total       int64         `json:"total"`
+		Borrowed    int64         `json:"borrowed"`
+		TotalAmount archive.Money `json:"total_amount"`
+	}
+
+	var summary []instSummary
+	if err := global.DB.Model(&archive.Archive{}).
+		Scopes(access.Scope()).
+		Select("inst_no, COUNT(*) AS total, SUM(CASE WHEN borrow_state = '1' THEN 1 ELSE 0 END) AS borrowed, SUM(amount) AS total_amount").
+		Group("inst_no").
+		Order("inst_no").
+		Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取档案统计成功",
+		"data":    summary,
 	})
 }
+
+// LegacyValues 类型转换时无法解析的历史金额、日期，供人工修正
+func LegacyValues(c *gin.Context) {
+	var values []archive.LegacyValue
+	if err := global.DB.Order("id").Find(&values).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(values),
+		"list":  values,
+	})
+}
+
+// fileNoTemplate 档案类型对应的编号模板，类型未设置时使用系统默认模板
+func fileNoTemplate(t *archive.ArchiveType) string {
+	if t != nil && t.FileNoTemplate != "" {
+		return t.FileNoTemplate
+	}
+	return global.Conf.FileNoTemplate
+}
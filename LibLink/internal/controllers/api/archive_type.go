@@ -0,0 +1,158 @@
+package api
+
+import (
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/models/archive"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ArchiveTypes 档案类型列表，含自定义字段定义，供前端生成表单与筛选条件
+func ArchiveTypes(c *gin.Context) {
+	db := global.DB.Order("code")
+	if c.Query("all") != "true" {
+		db = db.Where("disabled = ?", false)
+	}
+
+	var types []archive.ArchiveType
+	if err := db.Find(&types).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(types),
+		"list":  types,
+	})
+}
+
+// AddArchiveType 新增档案类型
+func AddArchiveType(c *gin.Context) {
+	var msg message.ArchiveTypeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	t := archive.ArchiveType{
+		Code:           strings.TrimSpace(msg.Code),
+		Name:           strings.TrimSpace(msg.Name),
+		RetentionYears: msg.RetentionYears,
+		FileNoTemplate: strings.TrimSpace(msg.FileNoTemplate),
+		Fields:         msg.Fields,
+		Disabled:       msg.Disabled,
+	}
+	if err := t.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&archive.ArchiveType{}).Where("code = ?", t.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "类型代码已存在"})
+		return
+	}
+	// 清理此前软删除遗留的同代码记录，否则唯一索引冲突
+	if err := global.DB.Unscoped().Where("code = ? AND deleted_at IS NOT NULL", t.Code).Delete(&archive.ArchiveType{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	if err := global.DB.Create(&t).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "创建档案类型失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "archive_type.add", "archive_type", t.Code, t)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "档案类型创建成功",
+		"data":    t,
+	})
+}
+
+// UpdateArchiveType 修改档案类型，类型代码不可修改
+// 已有档案的自定义字段取值保持不变，下次编辑时按新的定义校验
+func UpdateArchiveType(c *gin.Context) {
+	var t archive.ArchiveType
+	if err := global.DB.Where("code = ?", c.Param("code")).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "档案类型不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var msg message.ArchiveTypeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	old := t
+	t.Name = strings.TrimSpace(msg.Name)
+	t.RetentionYears = msg.RetentionYears
+	t.FileNoTemplate = strings.TrimSpace(msg.FileNoTemplate)
+	t.Fields = msg.Fields
+	t.Disabled = msg.Disabled
+	if err := t.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":             t.Name,
+		"retention_years":  t.RetentionYears,
+		"file_no_template": t.FileNoTemplate,
+		"fields":           t.Fields,
+		"disabled":         t.Disabled,
+	}
+	if err := global.DB.Model(&t).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新档案类型失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "archive_type.update", "archive_type", t.Code, gin.H{"old": old, "new": t})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "档案类型更新成功",
+		"data":    t,
+	})
+}
+
+// DeleteArchiveType 删除档案类型，仍有该类型的档案时不可删除，可改为停用
+// 删除后类型代码可重新使用
+func DeleteArchiveType(c *gin.Context) {
+	var t archive.ArchiveType
+	if err := global.DB.Where("code = ?", c.Param("code")).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "档案类型不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&archive.Archive{}).Where("arc_type = ?", t.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "该类型下仍有档案，可改为停用"})
+		return
+	}
+
+	// 类型代码有唯一索引，直接删除记录以便之后重新添加同一代码
+	if err := global.DB.Unscoped().Delete(&t).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除档案类型失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "archive_type.delete", "archive_type", t.Code, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "档案类型删除成功"})
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"liblink/internal/global"
+	"liblink/internal/jobs"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// accessibleArchive 获取当前用户有权访问的档案，权限与网点范围不满足时直接写入响应
+func accessibleArchive(c *gin.Context, archiveID uint) (*archive.Archive, bool) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+
+	var arc archive.Archive
+	if err := global.DB.First(&arc, archiveID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "档案不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+
+	if !archive.CheckPermission(arc.GroupPermission, currentUser.PermissionGroup) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权访问该档案"})
+		return nil, false
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	if !access.Allows(arc.InstNo) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权访问其他网点的档案"})
+		return nil, false
+	}
+	return &arc, true
+}
+
+// paramID 解析路径中的数字ID，无效时直接写入响应
+func paramID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "ID无效"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// accessibleAttachment 获取附件并校验所属档案的访问权限
+func accessibleAttachment(c *gin.Context) (*archive.Attachment, *archive.Archive, bool) {
+	id, ok := paramID(c, "attachment_id")
+	if !ok {
+		return nil, nil, false
+	}
+	var att archive.Attachment
+	if err := global.DB.First(&att, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "附件不存在"})
+			return nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, nil, false
+	}
+	arc, ok := accessibleArchive(c, att.ArchiveID)
+	if !ok {
+		return nil, nil, false
+	}
+	return &att, arc, true
+}
+
+// Attachments 档案的附件列表
+func Attachments(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	if _, ok := accessibleArchive(c, id); !ok {
+		return
+	}
+
+	var attachments []archive.Attachment
+	if err := global.DB.Where("archive_id = ?", id).Order("id").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(attachments),
+		"list":  attachments,
+	})
+}
+
+// receiveUpload 校验上传的文件并写入存储，返回尚未保存到数据库的版本记录
+// 仅支持 PDF、JPEG、TIFF，可通过表单字段 sha256 提供客户端计算的校验和，不一致时拒绝保存
+func receiveUpload(c *gin.Context, archiveID uint) (*archive.AttachmentVersion, bool) {
+	maxSize := int64(global.Conf.Storage.MaxSizeMB) << 20
+	// 限制请求体大小，预留表单其他字段的空间
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "附件大小超过限制"})
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"message": "文件上传失败", "error": err.Error()})
+		return nil, false
+	}
+	if file.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": "附件大小超过限制"})
+		return nil, false
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "无法打开文件", "error": err.Error()})
+		return nil, false
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	contentType, ok := archive.DetectContentType(head[:n])
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"message": "仅支持 PDF、JPEG、TIFF 格式的附件"})
+		return nil, false
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "无法读取文件", "error": err.Error()})
+		return nil, false
+	}
+
+	hash := sha256.New()
+	key := archive.NewStorageKey(archiveID)
+	if err := global.Storage.Put(c.Request.Context(), key, io.TeeReader(f, hash), file.Size, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存附件失败", "error": err.Error()})
+		return nil, false
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	if expected := strings.ToLower(strings.TrimSpace(c.PostForm("sha256"))); expected != "" && expected != checksum {
+		global.Storage.Delete(c.Request.Context(), key)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "文件校验和不一致，请重新上传"})
+		return nil, false
+	}
+
+	return &archive.AttachmentVersion{
+		FileName:    filepath.Base(filepath.Clean("/" + file.Filename)),
+		ContentType: contentType,
+		Size:        file.Size,
+		SHA256:      checksum,
+		StorageKey:  key,
+		UploaderID:  middleware.GetEmail(c),
+	}, true
+}
+
+// saveUpload 保存版本记录并通知后台识别文字，失败时删除已写入存储的文件
+func saveUpload(c *gin.Context, att *archive.Attachment, v *archive.AttachmentVersion) bool {
+	if err := archive.SaveAttachmentVersion(global.DB, att, v); err != nil {
+		global.Storage.Delete(c.Request.Context(), v.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存附件失败", "error": err.Error()})
+		return false
+	}
+	jobs.WakeOCR()
+	return true
+}
+
+// UploadAttachment 上传档案扫描件，新建附件并作为第 1 个版本
+func UploadAttachment(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	arc, ok := accessibleArchive(c, id)
+	if !ok {
+		return
+	}
+
+	v, ok := receiveUpload(c, arc.ID)
+	if !ok {
+		return
+	}
+	att := archive.Attachment{ArchiveID: arc.ID}
+	if !saveUpload(c, &att, v) {
+		return
+	}
+
+	audit(c, "attachment.upload", "archive", arc.ContractNo, gin.H{"attachment_id": att.ID, "file_name": att.FileName, "sha256": att.SHA256})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "附件上传成功",
+		"data":    att,
+	})
+}
+
+// UploadAttachmentVersion 重新扫描后上传附件的新版本，历史版本保留
+func UploadAttachmentVersion(c *gin.Context) {
+	att, arc, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	v, ok := receiveUpload(c, arc.ID)
+	if !ok {
+		return
+	}
+	if !saveUpload(c, att, v) {
+		return
+	}
+
+	audit(c, "attachment.version", "archive", arc.ContractNo, gin.H{"attachment_id": att.ID, "version": v.Version, "file_name": v.FileName, "sha256": v.SHA256})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "附件新版本上传成功",
+		"data":    att,
+	})
+}
+
+// AttachmentVersions 附件的全部版本，最新的在前
+func AttachmentVersions(c *gin.Context) {
+	att, _, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	var versions []archive.AttachmentVersion
+	if err := global.DB.Where("attachment_id = ?", att.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(versions),
+		"list":  versions,
+	})
+}
+
+// DownloadAttachment 下载附件，默认为当前版本，version 指定历史版本，inline=true 时在浏览器中预览
+func DownloadAttachment(c *gin.Context) {
+	att, _, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	version := archive.AttachmentVersion{
+		FileName:    att.FileName,
+		ContentType: att.ContentType,
+		Size:        att.Size,
+		SHA256:      att.SHA256,
+		StorageKey:  att.StorageKey,
+	}
+	if v := c.Query("version"); v != "" {
+		if err := global.DB.Where("attachment_id = ? AND version = ?", att.ID, v).First(&version).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "附件版本不存在"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+	}
+
+	r, err := global.Storage.Get(c.Request.Context(), version.StorageKey)
+	if err != nil {
+		global.Logger.Error("read attachment error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "读取附件失败"})
+		return
+	}
+	defer r.Close()
+
+	disposition := "attachment"
+	if c.Query("inline") == "true" {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, version.Size, version.ContentType, r, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{"filename": version.FileName}),
+		"ETag":                `"` + version.SHA256 + `"`,
+	})
+}
+
+// AttachmentText 附件当前版本的 OCR 识别状态与识别出的文字
+func AttachmentText(c *gin.Context) {
+	att, _, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	var text archive.AttachmentText
+	if err := global.DB.First(&text, att.ID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "附件尚未识别"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": text})
+}
+
+// RecognizeAttachment 重新识别附件的当前版本，用于识别失败或更换识别语言后
+func RecognizeAttachment(c *gin.Context) {
+	att, _, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+	if global.OCR == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "未启用文字识别"})
+		return
+	}
+
+	if err := archive.RetryOCR(global.DB, att); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	jobs.WakeOCR()
+
+	c.JSON(http.StatusOK, gin.H{"message": "已加入识别队列"})
+}
+
+// DeleteAttachment 删除附件的全部版本及存储中的文件
+func DeleteAttachment(c *gin.Context) {
+	att, arc, ok := accessibleAttachment(c)
+	if !ok {
+		return
+	}
+
+	var keys []string
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&archive.AttachmentVersion{}).Where("attachment_id = ?", att.ID).Pluck("storage_key", &keys).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attachment_id = ?", att.ID).Delete(&archive.AttachmentVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(att).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除附件失败", "error": err.Error()})
+		return
+	}
+	for _, key := range keys {
+		if err := global.Storage.Delete(c.Request.Context(), key); err != nil {
+			global.Logger.Error("delete attachment object error: " + err.Error())
+		}
+	}
+
+	audit(c, "attachment.delete", "archive", arc.ContractNo, gin.H{"attachment_id": att.ID, "file_name": att.FileName, "versions": len(keys)})
+
+	c.JSON(http.StatusOK, gin.H{"message": "附件删除成功"})
+}
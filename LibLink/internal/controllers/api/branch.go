@@ -0,0 +1,218 @@
+package api
+
+import (
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/branch"
+	"liblink/internal/models/user"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// branchAccess 当前用户可访问的网点范围
+type branchAccess struct {
+	instNos []string
+	all     bool
+}
+
+func getBranchAccess(u *user.User) (*branchAccess, error) {
+	instNos, all, err := branch.AccessibleInstNos(global.DB, u)
+	if err != nil {
+		return nil, err
+	}
+	return &branchAccess{instNos: instNos, all: all}, nil
+}
+
+// currentBranchAccess 获取当前请求用户的网点范围，失败时直接写入响应
+func currentBranchAccess(c *gin.Context) (*branchAccess, bool) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	return access, true
+}
+
+// Allows 判断是否可以访问指定网点
+func (b *branchAccess) Allows(instNo string) bool {
+	return b.all || branch.Contains(b.instNos, instNo)
+}
+
+// Scope 档案查询的网点限定条件
+func (b *branchAccess) Scope() func(db *gorm.DB) *gorm.DB {
+	return archive.InBranches(b.instNos, b.all)
+}
+
+// Branches 网点树
+func Branches(c *gin.Context) {
+	tree, err := branch.GetTree(global.DB, c.Query("parent_no"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tree})
+}
+
+// AddBranch 新增网点
+func AddBranch(c *gin.Context) {
+	var msg message.AddBranchMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	msg.InstNo = strings.TrimSpace(msg.InstNo)
+	if msg.InstNo == "" || msg.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "网点编号与名称不能为空"})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&branch.Branch{}).Where("inst_no = ?", msg.InstNo).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "网点编号已存在"})
+		return
+	}
+
+	if err := branch.CheckParent(global.DB, msg.InstNo, msg.ParentNo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	b := branch.Branch{
+		InstNo:   msg.InstNo,
+		Name:     msg.Name,
+		ParentNo: msg.ParentNo,
+	}
+	if err := global.DB.Create(&b).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "创建网点失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "网点创建成功",
+		"data":    b,
+	})
+}
+
+// UpdateBranch 修改网点名称与上级网点，网点编号不可修改
+func UpdateBranch(c *gin.Context) {
+	var b branch.Branch
+	if err := global.DB.Where("inst_no = ?", c.Param("inst_no")).First(&b).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "网点不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var msg message.AddBranchMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if err := branch.CheckParent(global.DB, b.InstNo, msg.ParentNo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":      msg.Name,
+		"parent_no": msg.ParentNo,
+	}
+	if err := global.DB.Model(&b).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新网点失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "网点更新成功",
+		"data":    b,
+	})
+}
+
+// DeleteBranch 删除网点，存在下级网点或档案时不可删除
+func DeleteBranch(c *gin.Context) {
+	var b branch.Branch
+	if err := global.DB.Where("inst_no = ?", c.Param("inst_no")).First(&b).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "网点不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&branch.Branch{}).Where("parent_no = ?", b.InstNo).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "该网点存在下级网点"})
+		return
+	}
+	global.DB.Model(&archive.Archive{}).Where("inst_no = ?", b.InstNo).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "该网点下仍有档案"})
+		return
+	}
+
+	if err := global.DB.Delete(&b).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除网点失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "网点删除成功"})
+}
+
+// AssignBranches 设置用户所属网点
+func AssignBranches(c *gin.Context) {
+	var msg message.AssignBranchMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	instNos := branch.SplitInstNos(strings.Join(msg.InstNos, ","))
+	var count int64
+	global.DB.Model(&branch.Branch{}).Where("inst_no IN ?", instNos).Count(&count)
+	if int(count) != len(instNos) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "存在无效的网点编号"})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ?", msg.Email).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	old, instNosStr := u.InstNos, strings.Join(instNos, ",")
+	if err := global.DB.Model(&u).Update("inst_nos", instNosStr).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "设置所属网点失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.branch_assign", "user", u.Email, gin.H{"old": old, "new": instNosStr})
+
+	c.JSON(http.StatusOK, gin.H{"message": "所属网点设置成功"})
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxDeskCodes 柜台单次提交的编码数上限
+const maxDeskCodes = 200
+
+// errNoneSucceeded 没有任何档案操作成功，不保留借还批次
+var errNoneSucceeded = errors.New("none succeeded")
+
+// deskResult 单个扫描编码的处理结果
+type deskResult struct {
+	Code       string `json:"code"`
+	ArchiveID  uint   `json:"archive_id,omitempty"`
+	ContractNo string `json:"contract_no,omitempty"`
+	FileNo     string `json:"file_no,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+}
+
+// DeskOperate 柜台扫码批量借阅或归还
+// 扫描的编码按档案编号或合同编号解析，逐份按单份借还的规则处理并返回各自的结果，
+// 成功的档案归入同一个借还批次，借阅记录通过 loan_id 关联到该批次
+func DeskOperate(c *gin.Context) {
+	var msg message.DeskOperateMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	var status string
+	switch msg.Operation {
+	case "borrow":
+		status = archive.LoanBorrow
+	case "return":
+		status = archive.LoanReturn
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"message": "operation 只能为 borrow 或 return"})
+		return
+	}
+	msg.BorrowerID = strings.TrimSpace(msg.BorrowerID)
+	if status == archive.LoanBorrow && msg.BorrowerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少借阅人"})
+		return
+	}
+	if len(msg.Codes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请扫描档案"})
+		return
+	}
+	if len(msg.Codes) > maxDeskCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("单次最多提交 %d 个编码", maxDeskCodes)})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	codes := make([]string, len(msg.Codes))
+	for i, code := range msg.Codes {
+		codes[i] = strings.TrimSpace(code)
+	}
+
+	// 一次查出全部编码对应的档案，只包含当前用户有权访问的档案
+	var archives []archive.Archive
+	if err := global.DB.Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope()).
+		Where("file_no IN ? OR contract_no IN ?", codes, codes).Find(&archives).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	matches := make(map[string][]*archive.Archive)
+	for i := range archives {
+		a := &archives[i]
+		matches[a.FileNo] = append(matches[a.FileNo], a)
+		if a.ContractNo != a.FileNo {
+			matches[a.ContractNo] = append(matches[a.ContractNo], a)
+		}
+	}
+
+	loan := archive.Loan{
+		Operation:  status,
+		Status:     archive.LoanApproved,
+		BorrowerID: msg.BorrowerID,
+		OperatorID: currentUser.Email,
+		Remark:     msg.Remark,
+		Total:      len(codes),
+	}
+	results := make([]deskResult, len(codes))
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&loan).Error; err != nil {
+			return err
+		}
+		// 借阅记录的借阅人为柜台登记的借阅人，未登记借阅人的归还记为经办人
+		recordUser := msg.BorrowerID
+		if recordUser == "" {
+			recordUser = currentUser.Email
+		}
+		ctx := context.WithValue(context.Background(), archive.ArchiveOperateUserID, recordUser)
+		ctx = context.WithValue(ctx, archive.ArchiveLoanID, loan.ID)
+
+		done := make(map[uint]bool)
+		for i, code := range codes {
+			results[i] = deskResult{Code: code}
+			found := matches[code]
+			if code == "" || len(found) == 0 {
+				results[i].Error = "未找到档案或无权访问"
+				continue
+			}
+			// 不同档案类型的档案编号可能相同
+			if len(found) > 1 {
+				results[i].Error = "编码对应多份档案，请扫描合同编号"
+				continue
+			}
+
+			a := found[0]
+			results[i].ArchiveID = a.ID
+			results[i].ContractNo = a.ContractNo
+			results[i].FileNo = a.FileNo
+			results[i].Title = a.Title
+			if done[a.ID] {
+				results[i].Error = "重复扫描"
+				continue
+			}
+			done[a.ID] = true
+
+			if err := operateArchiveByID(tx, a.ID, ctx, status, access); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Success = true
+			loan.Succeeded++
+		}
+
+		if loan.Succeeded == 0 {
+			return errNoneSucceeded
+		}
+		return tx.Model(&loan).UpdateColumn("succeeded", loan.Succeeded).Error
+	})
+	if errors.Is(err, errNoneSucceeded) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "没有可以操作的档案",
+			"results": results,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "操作失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "loan."+msg.Operation, "loan", strconv.FormatUint(uint64(loan.ID), 10), gin.H{
+		"borrower_id": loan.BorrowerID,
+		"total":       loan.Total,
+		"succeeded":   loan.Succeeded,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("操作完成，成功 %d 份，失败 %d 份", loan.Succeeded, loan.Total-loan.Succeeded),
+		"data":    loan,
+		"results": results,
+	})
+}
+
+// Loans 借还批次列表，可按借阅人、操作与审批状态筛选
+// 只返回当前用户经办或申请的批次，以及包含其有权访问的档案的批次
+func Loans(c *gin.Context) {
+	type loanRequest struct {
+		message.RequestMsg
+		BorrowerID string `form:"borrower_id"`
+		Operation  string `form:"operation"`
+		Status     string `form:"status"`
+	}
+	var request loanRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	accessible := global.DB.Model(&archive.Archive{}).Select("contract_no").
+		Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope())
+	loanIDs := global.DB.Model(&archive.ArchiveRecord{}).Select("loan_id").
+		Where("loan_id <> 0 AND contract_no IN (?)", accessible)
+	// 待审批的申请尚无借阅记录，通过申请的档案判断
+	accessibleIDs := global.DB.Model(&archive.Archive{}).Select("id").
+		Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope())
+	requestIDs := global.DB.Model(&archive.LoanItem{}).Select("loan_id").Where("archive_id IN (?)", accessibleIDs)
+
+	db := global.DB.Model(&archive.Loan{}).Where("operator_id = ? OR borrower_id = ? OR id IN (?) OR id IN (?)",
+		currentUser.Email, currentUser.Email, loanIDs, requestIDs)
+	if request.BorrowerID != "" {
+		db = db.Where("borrower_id = ?", request.BorrowerID)
+	}
+	if request.Operation != "" {
+		db = db.Where("operation = ?", request.Operation)
+	}
+	if request.Status != "" {
+		db = db.Where("status = ?", request.Status)
+	}
+
+	var loans []archive.Loan
+	page, ok := paginate(c, &request.RequestMsg, db, "id", true, &loans)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"message": "获取借还批次成功",
+		"data":    loans,
+	}
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
+}
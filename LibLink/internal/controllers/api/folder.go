@@ -35,7 +35,7 @@ func GetFolders(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": folders})
+	c.JSON(http.StatusOK, gin.H{"data": shapeFolders(c, folders)})
 }
 
 // 创建文件夹
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/location"
+	"liblink/pkg/label"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxLabels 单次打印的标签数上限
+const maxLabels = 500
+
+// archiveCode 档案标签编码的内容，field 为 file_no 或 contract_no
+func archiveCode(a *archive.Archive, field string) string {
+	if field == "contract_no" {
+		return a.ContractNo
+	}
+	return a.FileNo
+}
+
+// writeBarcode 按查询参数生成条形码或二维码图片并写入响应
+func writeBarcode(c *gin.Context, content string) {
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "编号为空，无法生成标签"})
+		return
+	}
+	kind := c.DefaultQuery("kind", label.KindCode128)
+	if kind != label.KindCode128 && kind != label.KindQR {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "kind 只能为 code128 或 qr"})
+		return
+	}
+	width, _ := strconv.Atoi(c.Query("width"))
+	height, _ := strconv.Atoi(c.Query("height"))
+	if width <= 0 || width > 2000 {
+		width = 400
+	}
+	if height <= 0 || height > 2000 {
+		height = 120
+		if kind == label.KindQR {
+			height = width
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := label.WritePNG(&buf, kind, content, width, height); err != nil {
+		if errors.Is(err, label.ErrContent) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "该编号无法生成条形码，可改用二维码"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成标签失败", "error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// ArchiveBarcode 档案的条形码或二维码图片，kind 为 code128 或 qr，field 为 file_no 或 contract_no
+func ArchiveBarcode(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	arc, ok := accessibleArchive(c, id)
+	if !ok {
+		return
+	}
+	writeBarcode(c, archiveCode(arc, c.Query("field")))
+}
+
+// LocationBarcode 位置（如档案盒）的条形码或二维码图片，编码为位置编码
+func LocationBarcode(c *gin.Context) {
+	l, ok := findLocation(c)
+	if !ok {
+		return
+	}
+	writeBarcode(c, l.Code)
+}
+
+// LabelSheet 生成档案与档案盒的 PDF 标签，按请求中的顺序排列
+func LabelSheet(c *gin.Context) {
+	var msg message.LabelSheetMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if len(msg.ArchiveIDs)+len(msg.LocationIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请选择需要打印标签的档案或档案盒"})
+		return
+	}
+	if len(msg.ArchiveIDs)+len(msg.LocationIDs) > maxLabels {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("单次最多打印 %d 个标签", maxLabels)})
+		return
+	}
+
+	archives := make([]*archive.Archive, 0, len(msg.ArchiveIDs))
+	locationIDs := append([]uint{}, msg.LocationIDs...)
+	for _, id := range msg.ArchiveIDs {
+		arc, ok := accessibleArchive(c, id)
+		if !ok {
+			return
+		}
+		if archiveCode(arc, msg.Field) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("档案 %d 的编号为空，无法生成标签", arc.ID)})
+			return
+		}
+		archives = append(archives, arc)
+		locationIDs = append(locationIDs, arc.LocationID)
+	}
+
+	var locations []location.Location
+	if len(msg.LocationIDs) > 0 {
+		if err := global.DB.Where("id IN ?", msg.LocationIDs).Find(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+	}
+	byID := make(map[uint]location.Location, len(locations))
+	for _, l := range locations {
+		byID[l.ID] = l
+	}
+	paths, err := location.Paths(global.DB, locationIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	labels := make([]label.Label, 0, len(archives)+len(msg.LocationIDs))
+	for _, a := range archives {
+		lines := []string{a.Title}
+		if msg.Field == "contract_no" {
+			lines = append(lines, a.FileNo)
+		} else {
+			lines = append(lines, a.ContractNo)
+		}
+		if path := paths[a.LocationID]; path != "" {
+			lines = append(lines, path)
+		}
+		labels = append(labels, label.Label{Code: archiveCode(a, msg.Field), Lines: lines})
+	}
+	for _, id := range msg.LocationIDs {
+		l, ok := byID[id]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"message": fmt.Sprintf("位置 %d 不存在", id)})
+			return
+		}
+		labels = append(labels, label.Label{Code: l.Code, Lines: []string{paths[l.ID]}})
+	}
+
+	conf := global.Conf.Label
+	sheet := label.Sheet{
+		Columns:    conf.Columns,
+		Rows:       conf.Rows,
+		Width:      conf.Width,
+		Height:     conf.Height,
+		MarginLeft: conf.MarginLeft,
+		MarginTop:  conf.MarginTop,
+		FontPath:   conf.FontPath,
+		Border:     conf.Border,
+	}
+	var buf bytes.Buffer
+	if err := sheet.Write(&buf, labels); err != nil {
+		global.Logger.Error("generate label sheet error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成标签失败"})
+		return
+	}
+
+	fileName := fmt.Sprintf("labels-%s.pdf", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// LookupCode 将扫描到的编码解析为档案或位置
+// 先按档案编号与合同编号匹配当前用户有权访问的档案，未找到时再按位置编码匹配
+func LookupCode(c *gin.Context) {
+	code := strings.TrimSpace(c.Query("code"))
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "缺少编码"})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	// 合同编号唯一，但不同档案类型的档案编号可能相同，因此返回列表
+	var archives []archive.Archive
+	if err := global.DB.Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope()).
+		Where("file_no = ? OR contract_no = ?", code, code).Order("id").Limit(20).Find(&archives).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if len(archives) > 0 {
+		ids := make([]uint, len(archives))
+		for i, a := range archives {
+			ids[i] = a.LocationID
+		}
+		paths, err := location.Paths(global.DB, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "查找成功",
+			"type":      "archive",
+			"data":      shapeArchives(c, archives),
+			"locations": paths,
+		})
+		return
+	}
+
+	var l location.Location
+	if err := global.DB.Where("code = ?", code).First(&l).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "未找到该编码对应的档案或位置"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	paths, err := location.Paths(global.DB, []uint{l.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "查找成功",
+		"type":    "location",
+		"data":    l,
+		"path":    paths[l.ID],
+	})
+}
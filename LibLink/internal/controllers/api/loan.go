@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errLoanHandled 借阅申请已被其他审批人处理
+var errLoanHandled = errors.New("loan handled")
+
+// RequestLoan 提交借阅申请，由本网点拥有审批权限的用户审批后借出
+func RequestLoan(c *gin.Context) {
+	var msg message.LoanRequestMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if len(msg.ArchiveIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请选择档案"})
+		return
+	}
+	if len(msg.ArchiveIDs) > maxDeskCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("单次最多申请 %d 份档案", maxDeskCodes)})
+		return
+	}
+
+	seen := make(map[uint]bool)
+	items := make([]archive.LoanItem, 0, len(msg.ArchiveIDs))
+	for _, id := range msg.ArchiveIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		arc, ok := accessibleArchive(c, id)
+		if !ok {
+			return
+		}
+		if arc.BorrowState == archive.LoanBorrow {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("档案 %s 已借出", arc.ContractNo)})
+			return
+		}
+		items = append(items, archive.LoanItem{ArchiveID: id})
+	}
+
+	email := middleware.GetEmail(c)
+	loan := archive.Loan{
+		Operation:  archive.LoanBorrow,
+		Status:     archive.LoanPending,
+		BorrowerID: email,
+		OperatorID: email,
+		Remark:     msg.Remark,
+		Total:      len(items),
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&loan).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].LoanID = loan.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "提交借阅申请失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "loan.request", "loan", strconv.FormatUint(uint64(loan.ID), 10), gin.H{"archive_ids": msg.ArchiveIDs})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "借阅申请已提交，等待审批",
+		"data":    loan,
+	})
+}
+
+// pendingLoan 获取待审批的借阅申请及其档案，需要对每份档案都有访问权限，失败时直接写入响应
+func pendingLoan(c *gin.Context) (*archive.Loan, []archive.LoanItem, bool) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return nil, nil, false
+	}
+	var loan archive.Loan
+	if err := global.DB.First(&loan, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "借阅申请不存在"})
+			return nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, nil, false
+	}
+	if loan.Status != archive.LoanPending {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "借阅申请已处理"})
+		return nil, nil, false
+	}
+	if loan.BorrowerID == middleware.GetEmail(c) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "不能审批自己的借阅申请"})
+		return nil, nil, false
+	}
+
+	var items []archive.LoanItem
+	if err := global.DB.Where("loan_id = ?", loan.ID).Order("id").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, nil, false
+	}
+	// 只能审批本网点档案的借阅
+	for _, item := range items {
+		if _, ok := accessibleArchive(c, item.ArchiveID); !ok {
+			return nil, nil, false
+		}
+	}
+	return &loan, items, true
+}
+
+// ApproveLoan 审批通过借阅申请，逐份借出档案，借阅记录关联到申请批次
+func ApproveLoan(c *gin.Context) {
+	loan, items, ok := pendingLoan(c)
+	if !ok {
+		return
+	}
+	access, ok := currentBranchAccess(c)
+	if !ok {
+		return
+	}
+
+	results := make([]deskResult, len(items))
+	loan.Succeeded = 0
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		ctx := context.WithValue(context.Background(), archive.ArchiveOperateUserID, loan.BorrowerID)
+		ctx = context.WithValue(ctx, archive.ArchiveLoanID, loan.ID)
+
+		for i, item := range items {
+			results[i] = deskResult{ArchiveID: item.ArchiveID}
+			if err := operateArchiveByID(tx, item.ArchiveID, ctx, archive.LoanBorrow, access); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].Success = true
+			loan.Succeeded++
+		}
+		if loan.Succeeded == 0 {
+			return errNoneSucceeded
+		}
+
+		// 条件更新，防止同一申请被重复审批
+		result := tx.Model(loan).Where("status = ?", archive.LoanPending).UpdateColumns(map[string]interface{}{
+			"status":      archive.LoanApproved,
+			"approver_id": middleware.GetEmail(c),
+			"succeeded":   loan.Succeeded,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLoanHandled
+		}
+		return nil
+	})
+	if errors.Is(err, errNoneSucceeded) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "没有可以借出的档案",
+			"results": results,
+		})
+		return
+	}
+	if errors.Is(err, errLoanHandled) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "借阅申请已处理"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "审批失败", "error": err.Error()})
+		return
+	}
+	loan.Status = archive.LoanApproved
+	loan.ApproverID = middleware.GetEmail(c)
+
+	audit(c, "loan.approve", "loan", strconv.FormatUint(uint64(loan.ID), 10), gin.H{
+		"borrower_id": loan.BorrowerID,
+		"total":       loan.Total,
+		"succeeded":   loan.Succeeded,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("审批通过，借出 %d 份，失败 %d 份", loan.Succeeded, loan.Total-loan.Succeeded),
+		"data":    loan,
+		"results": results,
+	})
+}
+
+// RejectLoan 驳回借阅申请
+func RejectLoan(c *gin.Context) {
+	loan, _, ok := pendingLoan(c)
+	if !ok {
+		return
+	}
+
+	result := global.DB.Model(loan).Where("status = ?", archive.LoanPending).UpdateColumns(map[string]interface{}{
+		"status":      archive.LoanRejected,
+		"approver_id": middleware.GetEmail(c),
+	})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "驳回失败", "error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "借阅申请已处理"})
+		return
+	}
+	loan.Status = archive.LoanRejected
+	loan.ApproverID = middleware.GetEmail(c)
+
+	audit(c, "loan.reject", "loan", strconv.FormatUint(uint64(loan.ID), 10), gin.H{"borrower_id": loan.BorrowerID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "借阅申请已驳回",
+		"data":    loan,
+	})
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"errors"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/location"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findLocation 按路径参数获取位置，不存在时直接写入响应
+func findLocation(c *gin.Context) (*location.Location, bool) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	var l location.Location
+	if err := global.DB.First(&l, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "位置不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	return &l, true
+}
+
+// Locations 下级位置列表，附带已占用的容量，不传 parent_id 时返回库房
+func Locations(c *gin.Context) {
+	parentID, _ := strconv.ParseUint(c.Query("parent_id"), 10, 64)
+
+	var locations []location.Location
+	if err := global.DB.Where("parent_id = ?", parentID).Order("code").Find(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	used, err := location.UsedMap(global.DB, locations)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	type item struct {
+		location.Location
+		Used int64 `json:"used"`
+	}
+	list := make([]item, len(locations))
+	for i, l := range locations {
+		list[i] = item{Location: l, Used: used[l.ID]}
+	}
+
+	response := gin.H{
+		"message": "获取位置列表成功",
+		"total":   len(list),
+		"list":    list,
+	}
+	if parentID != 0 {
+		paths, err := location.Paths(global.DB, []uint{uint(parentID)})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		response["path"] = paths[uint(parentID)]
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AddLocation 新增位置
+func AddLocation(c *gin.Context) {
+	var msg message.LocationMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	l := location.Location{Code: msg.Code, Name: msg.Name, Level: msg.Level, ParentID: msg.ParentID, Capacity: msg.Capacity}
+	if err := location.Check(global.DB, &l); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	var count int64
+	global.DB.Model(&location.Location{}).Where("code = ?", l.Code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "位置编码已存在"})
+		return
+	}
+
+	if err := global.DB.Create(&l).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "新增位置失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "location.create", "location", strconv.FormatUint(uint64(l.ID), 10), l)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "位置新增成功",
+		"data":    l,
+	})
+}
+
+// UpdateLocation 修改位置的编码、名称与容量，层级与上级位置通过移动修改
+func UpdateLocation(c *gin.Context) {
+	l, ok := findLocation(c)
+	if !ok {
+		return
+	}
+	var msg message.LocationMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	l.Code = msg.Code
+	l.Name = msg.Name
+	l.Capacity = msg.Capacity
+	if err := location.Check(global.DB, l); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	var count int64
+	global.DB.Model(&location.Location{}).Where("code = ? AND id <> ?", l.Code, l.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "位置编码已存在"})
+		return
+	}
+	if l.Capacity > 0 {
+		used, err := location.Used(global.DB, l)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		if used > int64(l.Capacity) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "容量不能小于已占用数量"})
+			return
+		}
+	}
+
+	if err := global.DB.Save(l).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "修改位置失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "location.update", "location", strconv.FormatUint(uint64(l.ID), 10), l)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "位置修改成功",
+		"data":    l,
+	})
+}
+
+// DeleteLocation 删除位置，仅允许删除空位置
+func DeleteLocation(c *gin.Context) {
+	l, ok := findLocation(c)
+	if !ok {
+		return
+	}
+
+	used, err := location.Used(global.DB, l)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "该位置下仍有档案或下级位置"})
+		return
+	}
+
+	if err := global.DB.Delete(l).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除位置失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "location.delete", "location", strconv.FormatUint(uint64(l.ID), 10), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "位置删除成功"})
+}
+
+// MoveLocation 移动位置，如将档案盒连同其中的档案移到另一个层架
+func MoveLocation(c *gin.Context) {
+	l, ok := findLocation(c)
+	if !ok {
+		return
+	}
+	var msg message.MoveLocationMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	from := l.ParentID
+	if err := location.Move(global.DB, l, msg.ParentID, middleware.GetEmail(c), msg.Remark); err != nil {
+		var moveErr *location.MoveError
+		if errors.As(err, &moveErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": moveErr.Reason})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "移动位置失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "location.move", "location", strconv.FormatUint(uint64(l.ID), 10), gin.H{"from": from, "to": l.ParentID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "位置移动成功",
+		"data":    l,
+	})
+}
+
+// MoveArchives 将档案放入档案盒（上架或移库），需要对每份档案都有访问权限
+func MoveArchives(c *gin.Context) {
+	var msg message.MoveArchivesMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if len(msg.ArchiveIDs) == 0 || msg.LocationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请选择档案与目标档案盒"})
+		return
+	}
+	for _, id := range msg.ArchiveIDs {
+		if _, ok := accessibleArchive(c, id); !ok {
+			return
+		}
+	}
+
+	if err := location.MoveArchives(global.DB, msg.ArchiveIDs, msg.LocationID, middleware.GetEmail(c), msg.Remark); err != nil {
+		var moveErr *location.MoveError
+		if errors.As(err, &moveErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": moveErr.Reason})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "移动档案失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "archive.move", "location", strconv.FormatUint(uint64(msg.LocationID), 10), gin.H{"archive_ids": msg.ArchiveIDs})
+
+	c.JSON(http.StatusOK, gin.H{"message": "档案移动成功"})
+}
+
+// Movements 移动历史，可按档案或位置筛选
+func Movements(c *gin.Context) {
+	type movementRequest struct {
+		message.RequestMsg
+		TargetType string `form:"target_type"`
+		TargetID   uint   `form:"target_id"`
+	}
+	var request movementRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	db := global.DB.Model(&location.Movement{})
+	if request.TargetType != "" {
+		db = db.Where("target_type = ?", request.TargetType)
+	}
+	if request.TargetID != 0 {
+		// 查看档案的移动历史需要该档案的访问权限
+		if request.TargetType == location.TargetArchive {
+			if _, ok := accessibleArchive(c, request.TargetID); !ok {
+				return
+			}
+		}
+		db = db.Where("target_id = ?", request.TargetID)
+	}
+	if request.TargetType != location.TargetArchive || request.TargetID == 0 {
+		// 不限定档案时不返回无权访问的档案的移动记录
+		currentUser, err := middleware.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		access, err := getBranchAccess(currentUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+		accessible := global.DB.Model(&archive.Archive{}).Select("id").
+			Where("group_permission = ?", currentUser.PermissionGroup).Scopes(access.Scope())
+		db = db.Where("target_type <> ? OR target_id IN (?)", location.TargetArchive, accessible)
+	}
+
+	var movements []location.Movement
+	page, ok := paginate(c, &request.RequestMsg, db, "id", true, &movements)
+	if !ok {
+		return
+	}
+
+	ids := make([]uint, 0, len(movements)*2)
+	for _, m := range movements {
+		ids = append(ids, m.FromID, m.ToID)
+	}
+	paths, err := location.Paths(global.DB, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	response := gin.H{
+		"message":   "获取移动记录成功",
+		"data":      movements,
+		"locations": paths,
+	}
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
+}
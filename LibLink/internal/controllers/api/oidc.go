@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"liblink/internal/auth"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/user"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcStateCookie     = "liblink_oidc_state"
+	oidcStateCookiePath = "/auth/oidc"
+)
+
+// OIDCLogin 跳转到单点登录提供方
+func OIDCLogin(c *gin.Context) {
+	if global.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "未启用单点登录"})
+		return
+	}
+
+	verifier, challenge := auth.NewPKCE()
+	state := middleware.OIDCStateClaim{
+		State:    auth.RandomString(16),
+		Nonce:    auth.RandomString(16),
+		Verifier: verifier,
+	}
+	stateToken, err := middleware.MakeOIDCStateToken(state)
+	if err != nil {
+		global.Logger.Error("make oidc state token error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成token失败"})
+		return
+	}
+
+	authURL, err := global.OIDC.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, challenge)
+	if err != nil {
+		global.Logger.Error("oidc auth url error: " + err.Error())
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "认证服务不可用"})
+		return
+	}
+
+	// 回调为提供方发起的跨站跳转，需使用 Lax 才能携带 Cookie
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, stateToken, int(middleware.OIDCStateExpireDuration.Seconds()), oidcStateCookiePath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 单点登录回调，校验授权码后签发本系统的登录令牌
+func OIDCCallback(c *gin.Context) {
+	if global.OIDC == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "未启用单点登录"})
+		return
+	}
+
+	stateToken, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", c.Request.TLS != nil, true)
+
+	if errCode := c.Query("error"); errCode != "" {
+		global.Logger.Info("oidc provider error: " + errCode + " " + c.Query("error_description"))
+		oidcFail(c, http.StatusUnauthorized, "单点登录失败")
+		return
+	}
+
+	state, err := middleware.ParseOIDCStateToken(stateToken)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(c.Query("state"))) != 1 {
+		oidcFail(c, http.StatusBadRequest, "登录状态无效或已过期，请重新登录")
+		return
+	}
+
+	ctx := c.Request.Context()
+	rawIDToken, err := global.OIDC.Exchange(ctx, c.Query("code"), state.Verifier)
+	if err != nil {
+		global.Logger.Error("oidc exchange error: " + err.Error())
+		oidcFail(c, http.StatusServiceUnavailable, "认证服务不可用")
+		return
+	}
+	claims, err := global.OIDC.VerifyIDToken(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		global.Logger.Warn("oidc verify id token error: " + err.Error())
+		oidcFail(c, http.StatusUnauthorized, "单点登录失败")
+		return
+	}
+	ext, err := global.OIDC.MapClaims(claims)
+	if err != nil {
+		oidcFail(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	attempt := user.LoginLog{
+		Email:     ext.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	u, err := global.OIDC.Provision(global.DB, ext)
+	if errors.Is(err, auth.ErrAccountConflict) {
+		recordLogin(attempt, "account_conflict")
+		oidcFail(c, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		global.Logger.Error("oidc provision error: " + err.Error())
+		oidcFail(c, http.StatusInternalServerError, "数据库错误")
+		return
+	}
+	attempt.UserID = u.ID
+
+	if u.Status == user.StatusDisabled {
+		recordLogin(attempt, "disabled")
+		oidcFail(c, http.StatusForbidden, "账号已停用")
+		return
+	}
+
+	// 角色要求两步验证时同样只签发预认证令牌
+	if user.RequireTOTP(global.DB, u) {
+		preAuthToken, err := middleware.MakePreAuthToken(middleware.JWTClaim{Email: u.Email, Version: u.TokenVersion})
+		if err != nil {
+			global.Logger.Error("make pre auth token error: " + err.Error())
+			oidcFail(c, http.StatusInternalServerError, "生成token失败")
+			return
+		}
+		enroll := "false"
+		if !u.TOTPEnabled {
+			enroll = "true"
+		}
+		oidcFinish(c, url.Values{
+			"two_factor_required": {"true"},
+			"enroll_required":     {enroll},
+			"pre_auth_token":      {preAuthToken},
+		})
+		return
+	}
+
+	token, err := makeLoginToken(u, attempt)
+	if err != nil {
+		global.Logger.Error("make token error: " + err.Error())
+		oidcFail(c, http.StatusInternalServerError, "生成token失败")
+		return
+	}
+	oidcFinish(c, url.Values{"token": {token}})
+}
+
+// oidcFinish 配置了前端地址时通过 URL 片段传递结果，避免令牌出现在服务器日志中
+func oidcFinish(c *gin.Context, values url.Values) {
+	frontendURL := global.Conf.OIDC.FrontendURL
+	if frontendURL == "" {
+		resp := gin.H{}
+		for k := range values {
+			resp[k] = values.Get(k)
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	c.Redirect(http.StatusFound, frontendURL+"#"+values.Encode())
+}
+
+func oidcFail(c *gin.Context, status int, msg string) {
+	if global.Conf.OIDC.FrontendURL == "" {
+		c.JSON(status, gin.H{"message": msg})
+		return
+	}
+	c.Redirect(http.StatusFound, global.Conf.OIDC.FrontendURL+"#"+url.Values{"error": {msg}}.Encode())
+}
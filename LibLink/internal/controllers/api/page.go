@@ -0,0 +1,24 @@
+package api
+
+import (
+	"errors"
+	"liblink/internal/controllers/message"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// paginate 分页查询列表，失败时直接写入响应
+func paginate(c *gin.Context, request *message.RequestMsg, db *gorm.DB, column string, desc bool, dest interface{}) (*message.Page, bool) {
+	page, err := request.Paginate(db, column, desc, dest)
+	if errors.Is(err, message.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "分页游标无效，请从第一页重新查询"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	return page, true
+}
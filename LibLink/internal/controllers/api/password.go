@@ -0,0 +1,203 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"liblink/internal/auth"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/user"
+	"liblink/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const resetPasswordExpire = 30 * time.Minute
+
+var errExternalPassword = errors.New("外部账号的密码请在对应的认证系统中修改")
+
+// ChangePassword 修改当前用户密码，需校验旧密码
+func ChangePassword(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var msg message.ChangePasswordMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if currentUser.Source != user.SourceLocal {
+		c.JSON(http.StatusBadRequest, gin.H{"message": errExternalPassword.Error()})
+		return
+	}
+	if !auth.CheckPassword(currentUser, msg.OldPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "原密码错误"})
+		return
+	}
+	if msg.OldPassword == msg.NewPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "新密码不能与原密码相同"})
+		return
+	}
+
+	if err := setPassword(currentUser, msg.NewPassword, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "修改密码失败", "error": err.Error()})
+		return
+	}
+
+	// 旧令牌已失效，返回新令牌以保持当前会话
+	token, err := middleware.MakeClaimsToken(middleware.JWTClaim{Email: currentUser.Email, Version: currentUser.TokenVersion})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成token失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密码修改成功",
+		"token":   token,
+	})
+}
+
+// AdminResetPassword 管理员重置用户密码，用户下次登录时必须修改密码
+func AdminResetPassword(c *gin.Context) {
+	var msg message.AdminResetPasswordMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ?", msg.Email).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	if !canManageUser(c, &u) {
+		return
+	}
+	if u.Source != user.SourceLocal {
+		c.JSON(http.StatusBadRequest, gin.H{"message": errExternalPassword.Error()})
+		return
+	}
+
+	pwd := msg.NewPassword
+	if pwd == "" {
+		var err error
+		if pwd, err = temporaryPassword(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "生成临时密码失败", "error": err.Error()})
+			return
+		}
+	}
+
+	if err := setPassword(&u, pwd, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "重置密码失败", "error": err.Error()})
+		return
+	}
+	if err := user.ResetLoginFailures(global.DB, &u); err != nil {
+		global.Logger.Error("reset login failures error: " + err.Error())
+	}
+
+	audit(c, "user.password_reset", "user", u.Email, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "密码已重置，用户下次登录需修改密码",
+		"password": pwd,
+	})
+}
+
+// ForgotPassword 发送重置密码邮件，无论邮箱是否存在都返回成功
+func ForgotPassword(c *gin.Context) {
+	var msg message.EmailMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ? AND source = ?", msg.Email, user.SourceLocal).First(&u).Error; err == nil {
+		if err := sendResetPasswordEmail(&u); err != nil {
+			global.Logger.Error("send reset password email error: " + err.Error())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "if the account exists, a reset email has been sent"})
+}
+
+// ResetPassword 使用邮件中的一次性令牌重置密码
+func ResetPassword(c *gin.Context) {
+	var msg message.ResetPasswordMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先校验密码策略，避免令牌被无效请求消耗
+	if err := global.Conf.PasswordPolicy.Validate(msg.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	t, err := user.ConsumeToken(global.DB, user.TokenResetPassword, msg.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.First(&u, t.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": user.ErrInvalidToken.Error()})
+		return
+	}
+
+	if err := setPassword(&u, msg.NewPassword, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := user.ResetLoginFailures(global.DB, &u); err != nil {
+		global.Logger.Error("reset login failures error: " + err.Error())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "reset password successfully"})
+}
+
+// setPassword 校验密码策略后设置新密码，已签发的登录令牌全部失效
+func setPassword(u *user.User, pwd string, mustChange bool) error {
+	if err := global.Conf.PasswordPolicy.Validate(pwd); err != nil {
+		return err
+	}
+	hash, err := encryptPassword(pwd)
+	if err != nil {
+		return err
+	}
+	return user.SetPassword(global.DB, u, hash, mustChange)
+}
+
+// sendResetPasswordEmail 发送重置密码邮件
+func sendResetPasswordEmail(u *user.User) error {
+	token, err := user.IssueToken(global.DB, u.ID, user.TokenResetPassword, resetPasswordExpire)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/reset_password?token=%s", global.Conf.SiteURL, token)
+	return global.Mailer.Send(u.Email, "LibLink 重置密码", "请在 30 分钟内打开以下链接重置密码，如非本人操作请忽略：\n"+link)
+}
+
+// temporaryPassword 生成满足默认密码策略的临时密码
+func temporaryPassword() (string, error) {
+	s, err := utils.GenerateRandomString(12)
+	if err != nil {
+		return "", err
+	}
+	return s + "Aa1!", nil
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/user"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Permissions 权限目录
+func Permissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(user.Permissions),
+		"list":  user.Permissions,
+	})
+}
+
+// Roles 角色列表
+func Roles(c *gin.Context) {
+	var roles []user.Role
+	if err := global.DB.Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(roles),
+		"list":  roles,
+	})
+}
+
+// AddRole 新增角色
+func AddRole(c *gin.Context) {
+	var msg message.AddRoleMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if msg.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "角色名称不能为空"})
+		return
+	}
+
+	perms, err := user.NormalizePermissions(msg.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if !canGrantPermissions(c, nil, msg.Permissions) {
+		return
+	}
+
+	var count int64
+	global.DB.Model(&user.Role{}).Where("name = ?", msg.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "角色已存在"})
+		return
+	}
+
+	r := user.Role{
+		Name:        msg.Name,
+		Description: msg.Description,
+		Permissions: perms,
+		RequireTOTP: msg.RequireTOTP,
+	}
+	if err := global.DB.Create(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "创建角色失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "role.add", "role", r.Name, gin.H{"permissions": r.Permissions})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "角色创建成功",
+		"data":    r,
+	})
+}
+
+// UpdateRole 修改角色描述与权限，admin 角色的权限不可修改，不能修改自己所属的角色
+func UpdateRole(c *gin.Context) {
+	var r user.Role
+	if err := global.DB.First(&r, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "角色不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	var msg message.AddRoleMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	perms, err := user.NormalizePermissions(msg.Permissions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if r.Name == user.RoleAdmin {
+		perms = r.Permissions
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if r.Name == currentUser.Role {
+		c.JSON(http.StatusForbidden, gin.H{"message": "不能修改自己所属的角色"})
+		return
+	}
+	if !canGrantPermissions(c, &r, msg.Permissions) {
+		return
+	}
+
+	// 角色名称被用户引用，不允许修改
+	old := gin.H{"permissions": r.Permissions, "require_totp": r.RequireTOTP}
+	updates := map[string]interface{}{
+		"description":  msg.Description,
+		"permissions":  perms,
+		"require_totp": msg.RequireTOTP,
+	}
+	if err := global.DB.Model(&r).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新角色失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "role.update", "role", r.Name, gin.H{"old": old, "new": updates})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "角色更新成功",
+		"data":    r,
+	})
+}
+
+// DeleteRole 删除角色，内置角色与仍有用户使用的角色不可删除
+func DeleteRole(c *gin.Context) {
+	var r user.Role
+	if err := global.DB.First(&r, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "角色不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if r.BuiltIn {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "内置角色不可删除"})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&user.User{}).Where("role = ?", r.Name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "仍有用户使用该角色"})
+		return
+	}
+
+	if err := global.DB.Delete(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除角色失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "role.delete", "role", r.Name, gin.H{"permissions": r.Permissions})
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色删除成功"})
+}
+
+// AssignRole 为用户分配角色
+func AssignRole(c *gin.Context) {
+	var msg message.AssignRoleMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if _, err := user.GetRole(global.DB, msg.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "角色不存在"})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ?", msg.Email).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if u.Email == currentUser.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不能修改自己的角色"})
+		return
+	}
+	if !canManageUser(c, &u) {
+		return
+	}
+	if !canGrantRole(c, currentUser, msg.Role) {
+		return
+	}
+
+	old := u.Role
+	if err := global.DB.Model(&u).Update("role", msg.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "分配角色失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.role_assign", "user", u.Email, gin.H{"old": old, "new": msg.Role})
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色分配成功"})
+}
+
+// canGrantPermissions 校验当前用户能否授予权限，角色新增的权限必须是当前用户已拥有的
+// old 为修改前的角色，新增角色时为 nil，不满足时直接写入响应
+func canGrantPermissions(c *gin.Context, old *user.Role, perms []string) bool {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return false
+	}
+	own, err := user.GetRole(global.DB, currentUser.Role)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"message": "用户角色无效"})
+		return false
+	}
+
+	var added []string
+	for _, p := range perms {
+		if old == nil || !old.Has(strings.TrimSpace(p)) {
+			added = append(added, p)
+		}
+	}
+	if p := own.MissingPermission(added); p != "" {
+		c.JSON(http.StatusForbidden, gin.H{"message": "不能授予自己未拥有的权限", "permission": p})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// visibleSavedSearch 获取当前用户可使用的查询，不存在或不可见时直接写入响应
+func visibleSavedSearch(c *gin.Context) (*archive.SavedSearch, bool) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	id, ok := paramID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	var s archive.SavedSearch
+	if err := global.DB.Scopes(archive.VisibleTo(currentUser.Email, currentUser.PermissionGroup)).First(&s, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "查询不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	return &s, true
+}
+
+// ownSavedSearch 获取当前用户创建的查询，仅创建者可以修改与删除
+func ownSavedSearch(c *gin.Context) (*archive.SavedSearch, bool) {
+	s, ok := visibleSavedSearch(c)
+	if !ok {
+		return nil, false
+	}
+	if s.OwnerID != middleware.GetEmail(c) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "只能修改自己创建的查询"})
+		return nil, false
+	}
+	return s, true
+}
+
+// applySavedSearchMsg 校验请求并写入查询，失败时直接写入响应
+func applySavedSearchMsg(c *gin.Context, s *archive.SavedSearch) bool {
+	var msg message.SavedSearchMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return false
+	}
+
+	name := strings.TrimSpace(msg.Name)
+	if name == "" || len([]rune(name)) > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "名称不能为空且不超过 64 个字"})
+		return false
+	}
+	if _, ok := archiveSortColumns[msg.Sort]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不支持的排序字段"})
+		return false
+	}
+	if msg.Order != "" && msg.Order != "asc" && msg.Order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "排序方向只能为 asc 或 desc"})
+		return false
+	}
+
+	// 只能共享给自己所在的用户组
+	sharedGroup := strings.TrimSpace(msg.SharedGroup)
+	if sharedGroup != "" {
+		currentUser, err := middleware.CurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return false
+		}
+		if strings.Contains(sharedGroup, ",") || !archive.CheckPermission(sharedGroup, currentUser.PermissionGroup) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "只能共享给自己所在的用户组"})
+			return false
+		}
+	}
+
+	// 保存前按档案列表的规则校验筛选条件
+	v := &archive.Validator{}
+	if _, err := msg.Query.Scope(global.DB, v); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return false
+	}
+	if !v.Valid() {
+		validationFailed(c, v.Errors)
+		return false
+	}
+
+	s.Name = name
+	s.Q = strings.TrimSpace(msg.Q)
+	s.Query = msg.Query
+	s.Sort = msg.Sort
+	s.Order = msg.Order
+	s.SharedGroup = sharedGroup
+	return true
+}
+
+// SavedSearches 当前用户创建及共享给其用户组的查询，附带本人的订阅设置
+func SavedSearches(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var searches []archive.SavedSearch
+	if err := global.DB.Scopes(archive.VisibleTo(currentUser.Email, currentUser.PermissionGroup)).Order("id").Find(&searches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	var subs []archive.Subscription
+	if err := global.DB.Where("user_id = ?", currentUser.Email).Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	subscribed := make(map[uint]*archive.Subscription, len(subs))
+	for i := range subs {
+		subscribed[subs[i].SavedSearchID] = &subs[i]
+	}
+
+	type item struct {
+		archive.SavedSearch
+		Subscription *archive.Subscription `json:"subscription"`
+	}
+	list := make([]item, len(searches))
+	for i, s := range searches {
+		list[i] = item{SavedSearch: s, Subscription: subscribed[s.ID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(list),
+		"list":  list,
+	})
+}
+
+// AddSavedSearch 保存查询
+func AddSavedSearch(c *gin.Context) {
+	s := archive.SavedSearch{OwnerID: middleware.GetEmail(c)}
+	if !applySavedSearchMsg(c, &s) {
+		return
+	}
+
+	if err := global.DB.Create(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "查询保存成功",
+		"data":    s,
+	})
+}
+
+// UpdateSavedSearch 修改查询
+func UpdateSavedSearch(c *gin.Context) {
+	s, ok := ownSavedSearch(c)
+	if !ok {
+		return
+	}
+	if !applySavedSearchMsg(c, s) {
+		return
+	}
+
+	if err := global.DB.Save(s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "修改查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "查询修改成功",
+		"data":    s,
+	})
+}
+
+// DeleteSavedSearch 删除查询及其全部订阅
+func DeleteSavedSearch(c *gin.Context) {
+	s, ok := ownSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("saved_search_id = ?", s.ID).Delete(&archive.Subscription{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(s).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除查询失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "查询删除成功"})
+}
+
+// RunSavedSearch 执行查询，分页参数由请求提供，按当前用户的权限筛选
+func RunSavedSearch(c *gin.Context) {
+	s, ok := visibleSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	request := archiveQuery{Query: s.Query, Sort: s.Sort, Order: s.Order}
+	if err := c.ShouldBindQuery(&request.RequestMsg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	scope, err := global.Search.Scope(c.Request.Context(), s.Q)
+	if err != nil {
+		global.Logger.Error("search archives error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "检索失败"})
+		return
+	}
+	listArchives(c, &request, scope)
+}
+
+// SubscribeSavedSearch 订阅查询或修改订阅设置，至少选择一种通知
+func SubscribeSavedSearch(c *gin.Context) {
+	s, ok := visibleSavedSearch(c)
+	if !ok {
+		return
+	}
+
+	var msg message.SubscribeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if !msg.NotifyNew && !msg.NotifyBorrow {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请至少选择一种通知"})
+		return
+	}
+
+	email := middleware.GetEmail(c)
+	var sub archive.Subscription
+	err := global.DB.Where("saved_search_id = ? AND user_id = ?", s.ID, email).First(&sub).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	// 新订阅只通知订阅之后的变化
+	if sub.ID == 0 {
+		sub = archive.Subscription{SavedSearchID: s.ID, UserID: email, CheckedAt: time.Now()}
+	}
+	sub.NotifyNew = msg.NotifyNew
+	sub.NotifyBorrow = msg.NotifyBorrow
+
+	if err := global.DB.Save(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "订阅失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "订阅成功",
+		"data":    sub,
+	})
+}
+
+// UnsubscribeSavedSearch 取消订阅
+func UnsubscribeSavedSearch(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Where("saved_search_id = ? AND user_id = ?", id, middleware.GetEmail(c)).Delete(&archive.Subscription{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "取消订阅失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已取消订阅"})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/user"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// canViewSensitive 当前用户是否可以查看未脱敏的档案
+func canViewSensitive(c *gin.Context) bool {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		return false
+	}
+	return user.HasPermission(global.DB, currentUser, user.PermArchiveSensitive)
+}
+
+// shapeArchive 按当前用户权限对档案脱敏
+func shapeArchive(c *gin.Context, a *archive.Archive) archive.Archive {
+	if canViewSensitive(c) {
+		return *a
+	}
+	return a.Masked()
+}
+
+// shapeArchives 按当前用户权限对档案列表脱敏
+func shapeArchives(c *gin.Context, archives []archive.Archive) []archive.Archive {
+	if canViewSensitive(c) {
+		return archives
+	}
+	masked := make([]archive.Archive, len(archives))
+	for i, a := range archives {
+		masked[i] = a.Masked()
+	}
+	return masked
+}
+
+// shapeFolders 对文件夹树中的档案脱敏
+func shapeFolders(c *gin.Context, folders []archive.FileFolders) []archive.FileFolders {
+	if canViewSensitive(c) {
+		return folders
+	}
+	var maskFolders func([]archive.FileFolders)
+	maskFolders = func(nodes []archive.FileFolders) {
+		for i := range nodes {
+			for j := range nodes[i].Archives {
+				nodes[i].Archives[j] = nodes[i].Archives[j].Masked()
+			}
+			maskFolders(nodes[i].Children)
+		}
+	}
+	maskFolders(folders)
+	return folders
+}
+
+// unmaskedValue 客户端回传的值与脱敏结果相同时视为未修改，保留原值
+func unmaskedValue(submitted, original string, mask func(string) string) string {
+	if original != "" && submitted == mask(original) && submitted != original {
+		return original
+	}
+	return submitted
+}
+
+// RevealArchive 查看单个档案的敏感信息，必须填写原因并记录审计日志
+func RevealArchive(c *gin.Context) {
+	archiveID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "档案ID无效"})
+		return
+	}
+
+	var msg message.RevealArchiveMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if msg.Reason = strings.TrimSpace(msg.Reason); msg.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请填写查看原因"})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var arc archive.Archive
+	if err := global.DB.First(&arc, archiveID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "档案不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	if !archive.CheckPermission(arc.GroupPermission, currentUser.PermissionGroup) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权访问该档案"})
+		return
+	}
+	access, err := getBranchAccess(currentUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !access.Allows(arc.InstNo) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "无权访问其他网点的档案"})
+		return
+	}
+
+	audit(c, "archive.reveal", "archive", arc.ContractNo, gin.H{"id": arc.ID, "reason": msg.Reason})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取敏感信息成功",
+		"data": gin.H{
+			"id":          arc.ID,
+			"contract_no": arc.ContractNo,
+			"name":        arc.Name,
+			"id_card":     arc.IDCard,
+			"amount":      arc.Amount,
+		},
+	})
+}
@@ -1,38 +1,37 @@
 package api
 
 import (
-	"fmt"
 	"liblink/internal/controllers/message"
 	"liblink/internal/global"
 	"liblink/internal/middleware"
 	"liblink/internal/models/system"
-	"liblink/internal/models/user"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Notifications 通知列表，最新的在前
 func Notifications(c *gin.Context) {
+	var request message.RequestMsg
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
 	var notifications []system.Notification
-	global.DB.Find(&notifications)
+	page, ok := paginate(c, &request, global.DB.Model(&system.Notification{}), "id", true, &notifications)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"total": len(notifications),
-		"list":  notifications,
-	})
+	response := gin.H{"list": notifications}
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
 }
 
 func AddNotification(c *gin.Context) {
-	email := middleware.GetEmail(c)
-	err := checkRole(email)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
 	msg := &message.AddNotificationMsg{}
-	err = c.BindJSON(&msg)
+	err := c.BindJSON(&msg)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -53,11 +52,53 @@ func AddNotification(c *gin.Context) {
 	})
 }
 
-func checkRole(email string) error {
-	var u user.User
-	global.DB.Where("email = ?", email).First(&u)
-	if u.Email != "" && u.Role == "admin" {
-		return nil
+// audit 记录当前用户的敏感操作，失败时仅写日志不影响业务
+func audit(c *gin.Context, action, targetType, targetID string, detail interface{}) {
+	if err := system.AddAuditLog(global.DB, middleware.GetEmail(c), action, targetType, targetID, detail); err != nil {
+		global.Logger.Error("add audit log error: " + err.Error())
+	}
+}
+
+// AuditLogs 审计日志列表
+func AuditLogs(c *gin.Context) {
+	type auditRequest struct {
+		message.RequestMsg
+		OperatorID string `json:"operator_id" form:"operator_id"`
+		Action     string `json:"action" form:"action"`
+		TargetType string `json:"target_type" form:"target_type"`
+		TargetID   string `json:"target_id" form:"target_id"`
+	}
+
+	var request auditRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	db := global.DB.Model(&system.AuditLog{})
+	if request.OperatorID != "" {
+		db = db.Where("operator_id = ?", request.OperatorID)
+	}
+	if request.Action != "" {
+		db = db.Where("action = ?", request.Action)
+	}
+	if request.TargetType != "" {
+		db = db.Where("target_type = ?", request.TargetType)
+	}
+	if request.TargetID != "" {
+		db = db.Where("target_id = ?", request.TargetID)
+	}
+
+	var logs []system.AuditLog
+	page, ok := paginate(c, &request.RequestMsg, db, "id", true, &logs)
+	if !ok {
+		return
+	}
+
+	response := gin.H{
+		"message": "获取审计日志成功",
+		"data":    logs,
 	}
-	return fmt.Errorf("this user is not admin user")
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
 }
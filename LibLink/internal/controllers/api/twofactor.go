@@ -0,0 +1,355 @@
+package api
+
+import (
+	"errors"
+	"liblink/internal/auth"
+	"liblink/internal/controllers/message"
+	"liblink/internal/global"
+	"liblink/internal/middleware"
+	"liblink/internal/models/user"
+	"liblink/pkg/fieldcrypt"
+	"liblink/pkg/totp"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const totpIssuer = "LibLink"
+
+// preAuthUser 解析预认证令牌对应的用户
+func preAuthUser(token string) (*user.User, error) {
+	claims, err := middleware.ParseClaimsToken(token)
+	if err != nil || claims.Subject != middleware.SubjectPreAuth {
+		return nil, errors.New("预认证令牌无效或已过期")
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ?", claims.Email).First(&u).Error; err != nil ||
+		u.TokenVersion != claims.Version || u.Status != user.StatusActive {
+		return nil, errors.New("预认证令牌无效或已过期")
+	}
+	return &u, nil
+}
+
+// LoginTwoFactor 登录第二步，校验验证码或恢复码后签发登录令牌
+func LoginTwoFactor(c *gin.Context) {
+	var msg message.TwoFactorLoginMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, message.ErrorResponse(err))
+		return
+	}
+
+	u, err := preAuthUser(msg.PreAuthToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	if !u.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请先绑定两步验证"})
+		return
+	}
+
+	now := time.Now()
+	attempt := user.LoginLog{
+		UserID:    u.ID,
+		Email:     u.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if loginThrottled(c, u, attempt, now) {
+		return
+	}
+
+	var ok bool
+	if msg.RecoveryCode != "" {
+		ok, err = user.UseRecoveryCode(global.DB, u.ID, msg.RecoveryCode)
+	} else {
+		ok, err = user.VerifyTOTP(global.DB, u, msg.Code)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !ok {
+		loginFailed(c, u, attempt, now, "bad_totp", "验证码错误")
+		return
+	}
+
+	if u.FailedLogins > 0 || u.LastFailedAt != nil {
+		if err := user.ResetLoginFailures(global.DB, u); err != nil {
+			global.Logger.Error("reset login failures error: " + err.Error())
+		}
+	}
+
+	issueLoginToken(c, u, attempt, nil)
+}
+
+// LoginTwoFactorSetup 角色强制两步验证但用户尚未绑定时，使用预认证令牌生成密钥
+func LoginTwoFactorSetup(c *gin.Context) {
+	var msg message.TwoFactorLoginMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, message.ErrorResponse(err))
+		return
+	}
+
+	u, err := preAuthUser(msg.PreAuthToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	attempt := user.LoginLog{
+		UserID:    u.ID,
+		Email:     u.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if loginThrottled(c, u, attempt, time.Now()) {
+		return
+	}
+
+	setupTOTP(c, u)
+}
+
+// LoginTwoFactorActivate 使用预认证令牌完成绑定，返回恢复码与登录令牌
+// 与登录第二步相同，验证码错误计入登录失败次数
+func LoginTwoFactorActivate(c *gin.Context) {
+	var msg message.TwoFactorLoginMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, message.ErrorResponse(err))
+		return
+	}
+
+	u, err := preAuthUser(msg.PreAuthToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	if u.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "已启用两步验证"})
+		return
+	}
+
+	now := time.Now()
+	attempt := user.LoginLog{
+		UserID:    u.ID,
+		Email:     u.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if loginThrottled(c, u, attempt, now) {
+		return
+	}
+
+	ok, err := user.VerifyTOTP(global.DB, u, msg.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !ok {
+		loginFailed(c, u, attempt, now, "bad_totp", "验证码错误")
+		return
+	}
+	if u.FailedLogins > 0 || u.LastFailedAt != nil {
+		if err := user.ResetLoginFailures(global.DB, u); err != nil {
+			global.Logger.Error("reset login failures error: " + err.Error())
+		}
+	}
+
+	codes, ok := enableTOTP(c, u)
+	if !ok {
+		return
+	}
+	issueLoginToken(c, u, attempt, gin.H{"recovery_codes": codes})
+}
+
+// TOTPSetup 当前用户生成两步验证密钥
+func TOTPSetup(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	setupTOTP(c, currentUser)
+}
+
+// TOTPActivate 当前用户完成两步验证绑定
+func TOTPActivate(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var msg message.TOTPCodeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	codes, ok := activateTOTP(c, currentUser, msg.Code)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "两步验证已启用",
+		"recovery_codes": codes,
+	})
+}
+
+// TOTPDisable 当前用户关闭两步验证，角色强制要求时不可关闭
+func TOTPDisable(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var msg message.TOTPCodeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if role, err := user.GetRole(global.DB, currentUser.Role); err == nil && role.RequireTOTP {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "当前角色要求必须启用两步验证"})
+		return
+	}
+	if !verifyPassword(currentUser, msg.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "密码错误"})
+		return
+	}
+	if ok, err := user.VerifyTOTP(global.DB, currentUser, msg.Code); err != nil || !ok {
+		c.JSON(http.StatusForbidden, gin.H{"message": "验证码错误"})
+		return
+	}
+
+	if err := user.DisableTOTP(global.DB, currentUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "关闭两步验证失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// TOTPRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func TOTPRecoveryCodes(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var msg message.TOTPCodeMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	if !currentUser.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "尚未启用两步验证"})
+		return
+	}
+	if ok, err := user.VerifyTOTP(global.DB, currentUser, msg.Code); err != nil || !ok {
+		c.JSON(http.StatusForbidden, gin.H{"message": "验证码错误"})
+		return
+	}
+
+	codes, err := user.GenerateRecoveryCodes(global.DB, currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成恢复码失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "恢复码已重新生成",
+		"recovery_codes": codes,
+	})
+}
+
+// ResetUserTOTP 管理员清除用户的两步验证，用于设备丢失的情况
+func ResetUserTOTP(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok || !canManageUser(c, u) {
+		return
+	}
+
+	if err := user.DisableTOTP(global.DB, u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "重置两步验证失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.totp_reset", "user", u.Email, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已重置"})
+}
+
+// verifyPassword 通过用户来源对应的认证后端校验密码
+func verifyPassword(u *user.User, password string) bool {
+	if u.Source == user.SourceLocal {
+		return auth.CheckPassword(u, password)
+	}
+	authUser, err := auth.Authenticate(global.Authenticators, global.DB, u.Email, password)
+	return err == nil && authUser.ID == u.ID
+}
+
+// setupTOTP 为尚未启用两步验证的用户生成新密钥
+func setupTOTP(c *gin.Context, u *user.User) {
+	if u.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "已启用两步验证"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成密钥失败", "error": err.Error()})
+		return
+	}
+	if err := global.DB.Model(u).Updates(map[string]interface{}{
+		"totp_secret":       fieldcrypt.String(secret),
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totp.ProvisioningURI(totpIssuer, u.Email, secret),
+	})
+}
+
+// activateTOTP 校验验证码后启用两步验证并生成恢复码，失败时直接写入响应
+func activateTOTP(c *gin.Context, u *user.User, code string) ([]string, bool) {
+	if u.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "已启用两步验证"})
+		return nil, false
+	}
+
+	ok, err := user.VerifyTOTP(global.DB, u, code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"message": "验证码错误"})
+		return nil, false
+	}
+	return enableTOTP(c, u)
+}
+
+// enableTOTP 验证码校验通过后启用两步验证并生成恢复码，失败时直接写入响应
+func enableTOTP(c *gin.Context, u *user.User) ([]string, bool) {
+	if err := user.EnableTOTP(global.DB, u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "启用两步验证失败", "error": err.Error()})
+		return nil, false
+	}
+	codes, err := user.GenerateRecoveryCodes(global.DB, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成恢复码失败", "error": err.Error()})
+		return nil, false
+	}
+	return codes, true
+}
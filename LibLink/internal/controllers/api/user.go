@@ -3,14 +3,17 @@ package api
 import (
 	"errors"
 	"fmt"
+	"liblink/internal/auth"
 	"liblink/internal/controllers/message"
 	"liblink/internal/global"
 	"liblink/internal/middleware"
+	"liblink/internal/models/branch"
 	"liblink/internal/models/user"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -22,92 +25,466 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 检验用户
-	if !checkUser(u) {
-		c.JSON(http.StatusForbidden, gin.H{"message": "用户名或密码错误"})
+	now := time.Now()
+	protection := global.Conf.LoginProtection
+	window := time.Duration(protection.WindowMinute) * time.Minute
+	attempt := user.LoginLog{
+		Email:     u.Email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	// 单个 IP 失败次数过多时直接拒绝
+	ipFailures, err := user.CountIPFailures(global.DB, attempt.IP, now.Add(-window))
+	if err != nil {
+		global.Logger.Error("count ip failures error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if protection.IPMaxFailures > 0 && ipFailures >= int64(protection.IPMaxFailures) {
+		recordLogin(attempt, "ip_throttled")
+		c.JSON(http.StatusTooManyRequests, gin.H{"message": "登录尝试过于频繁，请稍后再试"})
 		return
 	}
 
-	// 返回对应 jwt 密钥
-	token, err := middleware.MakeClaimsToken(middleware.JWTClaim{Email: u.Email})
+	localUser, err := auth.FindLoginUser(global.DB, u.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		global.Logger.Error("query user error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	if localUser != nil {
+		attempt.UserID = localUser.ID
+		if loginThrottled(c, localUser, attempt, now) {
+			return
+		}
+	}
+
+	// 依次通过各认证后端检验用户
+	authUser, err := auth.Authenticate(global.Authenticators, global.DB, u.Email, u.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		if localUser == nil {
+			recordLogin(attempt, "bad_credentials")
+			c.JSON(http.StatusForbidden, gin.H{"message": "用户名或密码错误"})
+			return
+		}
+		loginFailed(c, localUser, attempt, now, "bad_credentials", "用户名或密码错误")
+		return
+	}
+	if errors.Is(err, auth.ErrAccountConflict) {
+		recordLogin(attempt, "account_conflict")
+		c.JSON(http.StatusForbidden, gin.H{"message": err.Error()})
+		return
+	}
 	if err != nil {
-		fmt.Println(err)
+		global.Logger.Error("authenticate error: " + err.Error())
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "认证服务不可用"})
+		return
 	}
+	dbUser := *authUser
+	attempt.UserID = dbUser.ID
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	if dbUser.Status == user.StatusUnverified {
+		recordLogin(attempt, "unverified")
+		c.JSON(http.StatusForbidden, gin.H{"message": "邮箱未验证"})
+		return
+	}
+
+	if dbUser.Status == user.StatusDisabled {
+		recordLogin(attempt, "disabled")
+		c.JSON(http.StatusForbidden, gin.H{"message": "账号已停用"})
+		return
+	}
 
+	if dbUser.FailedLogins > 0 || dbUser.LastFailedAt != nil {
+		if err := user.ResetLoginFailures(global.DB, &dbUser); err != nil {
+			global.Logger.Error("reset login failures error: " + err.Error())
+		}
+	}
+
+	// 需要两步验证时只签发预认证令牌
+	if user.RequireTOTP(global.DB, &dbUser) {
+		preAuthToken, err := middleware.MakePreAuthToken(middleware.JWTClaim{Email: dbUser.Email, Version: dbUser.TokenVersion})
+		if err != nil {
+			global.Logger.Error("make pre auth token error: " + err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "生成token失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"enroll_required":     !dbUser.TOTPEnabled,
+			"pre_auth_token":      preAuthToken,
+		})
+		return
+	}
+
+	issueLoginToken(c, &dbUser, attempt, nil)
 }
 
-// checkUser 检验用户
-func checkUser(u user.User) bool {
-	var dbUser user.User
-	result := global.DB.Where("email = ?", u.Email).First(&dbUser)
+// loginThrottled 检查账号是否被锁定或需要等待，是则直接写入响应
+func loginThrottled(c *gin.Context, u *user.User, attempt user.LoginLog, now time.Time) bool {
+	protection := global.Conf.LoginProtection
+	window := time.Duration(protection.WindowMinute) * time.Minute
 
-	// 检查是否找到用户
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return false
+	if u.IsLocked(now) {
+		recordLogin(attempt, "locked")
+		c.JSON(http.StatusLocked, gin.H{"message": "账号已锁定，请稍后再试或联系管理员", "locked_until": u.LockedUntil})
+		return true
 	}
 
-	// 检查是否有其他错误
-	if result.Error != nil {
-		fmt.Println(result.Error.Error())
-		return false
+	// 连续失败后需等待一段时间才能再次尝试
+	delay := user.LoginDelay(u.FailedLogins,
+		time.Duration(protection.BaseDelaySecond)*time.Second,
+		time.Duration(protection.MaxDelaySecond)*time.Second)
+	if u.LastFailedAt != nil && now.Sub(*u.LastFailedAt) < window {
+		if wait := u.LastFailedAt.Add(delay).Sub(now); wait > 0 {
+			recordLogin(attempt, "throttled")
+			c.JSON(http.StatusTooManyRequests, gin.H{"message": "登录尝试过于频繁，请稍后再试", "retry_after": int(wait.Seconds()) + 1})
+			return true
+		}
 	}
+	return false
+}
+
+// loginFailed 记录一次失败的登录，达到上限时锁定账号
+func loginFailed(c *gin.Context, u *user.User, attempt user.LoginLog, now time.Time, reason, msg string) {
+	protection := global.Conf.LoginProtection
+	recordLogin(attempt, reason)
 
-	// 检查密码是否正确
-	err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(u.Password))
+	locked, err := user.RecordLoginFailure(global.DB, u, now,
+		time.Duration(protection.WindowMinute)*time.Minute, protection.MaxFailures,
+		time.Duration(protection.LockMinute)*time.Minute)
 	if err != nil {
-		fmt.Println(err.Error())
-		return false
+		global.Logger.Error("record login failure error: " + err.Error())
+	}
+	if locked {
+		c.JSON(http.StatusLocked, gin.H{"message": "登录失败次数过多，账号已锁定", "locked_until": u.LockedUntil})
+		return
 	}
+	c.JSON(http.StatusForbidden, gin.H{"message": msg})
+}
 
-	return true
+// issueLoginToken 签发登录令牌并记录登录成功
+func issueLoginToken(c *gin.Context, u *user.User, attempt user.LoginLog, extra gin.H) {
+	token, err := makeLoginToken(u, attempt)
+	if err != nil {
+		global.Logger.Error("make token error: " + err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成token失败"})
+		return
+	}
+
+	resp := gin.H{"token": token, "must_change_password": u.MustChangePwd}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-func Register(c *gin.Context) {
+// makeLoginToken 返回对应 jwt 密钥并记录登录成功
+func makeLoginToken(u *user.User, attempt user.LoginLog) (string, error) {
+	token, err := middleware.MakeClaimsToken(middleware.JWTClaim{Email: u.Email, Version: u.TokenVersion})
+	if err != nil {
+		return "", err
+	}
+
+	attempt.Success = true
+	recordLogin(attempt, "")
+	return token, nil
+}
+
+// recordLogin 写入登录审计记录
+func recordLogin(attempt user.LoginLog, reason string) {
+	attempt.Reason = reason
+	if err := global.DB.Create(&attempt).Error; err != nil {
+		global.Logger.Error("record login error: " + err.Error())
+	}
+}
+
+// UnlockUser 管理员解除账号锁定
+func UnlockUser(c *gin.Context) {
+	var msg message.EmailMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
 	var u user.User
+	if err := global.DB.Where("email = ?", msg.Email).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	if !canManageUser(c, &u) {
+		return
+	}
 
-	if err := c.ShouldBindJSON(&u); err != nil {
+	if err := user.ResetLoginFailures(global.DB, &u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "解除锁定失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.unlock", "user", u.Email, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "账号已解除锁定"})
+}
+
+// MyLogins 当前用户最近的登录记录
+func MyLogins(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var logs []user.LoginLog
+	if err := global.DB.Where("user_id = ?", currentUser.ID).Order("id DESC").Limit(20).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(logs),
+		"list":  logs,
+	})
+}
+
+// Register 凭邀请码注册，角色与用户组由邀请决定，注册后需验证邮箱
+func Register(c *gin.Context) {
+	var msg message.RegisterMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if u.Email == "" || u.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱或密码不合法"})
+	if msg.Email == "" || msg.Password == "" || msg.InviteToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱、密码或邀请码不合法"})
+		return
+	}
+
+	if err := global.Conf.PasswordPolicy.Validate(msg.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var invite user.Invite
+	if err := global.DB.Where("token_hash = ?", user.HashToken(msg.InviteToken)).First(&invite).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邀请码无效"})
+		return
+	}
+	if invite.UsedAt != nil || time.Now().After(invite.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邀请码已使用或已过期"})
+		return
+	}
+	if !strings.EqualFold(invite.Email, msg.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱与邀请不符"})
 		return
 	}
 
 	var count int64
-	global.DB.Model(&user.User{}).Where("email = ?", u.Email).Count(&count)
+	global.DB.Model(&user.User{}).Where("email = ?", msg.Email).Count(&count)
 	if count > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "账号已存在"})
 		return
 	}
 
-	addUser(u)
+	u := user.User{
+		Username:        msg.Username,
+		Email:           msg.Email,
+		Password:        msg.Password,
+		Role:            invite.Role,
+		PermissionGroup: invite.PermissionGroup,
+		InstNos:         invite.InstNos,
+		Status:          user.StatusUnverified,
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		// 条件更新，防止同一邀请码被并发使用
+		result := tx.Model(&invite).Where("used_at IS NULL").Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("邀请码已使用")
+		}
+		return addUser(tx, &u)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := sendVerifyEmail(&u); err != nil {
+		global.Logger.Error("send verify email error: " + err.Error())
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
-		"msg":    "register successfully",
+		"msg":    "register successfully, please verify your email",
 	})
 }
 
 // addUser　创建用户
-func addUser(u user.User) {
-	u.Password, _ = encryptPassword(u.Password)
+func addUser(tx *gorm.DB, u *user.User) error {
+	var err error
+	u.Password, err = encryptPassword(u.Password)
+	if err != nil {
+		return err
+	}
 
-	result := global.DB.Create(&u)
-	if result.Error != nil {
-		global.Logger.Error(result.Error.Error())
+	if err := tx.Create(u).Error; err != nil {
+		global.Logger.Error(err.Error())
+		return err
 	}
+	return nil
 }
 
 // encryptPassword 生成加密结果
 func encryptPassword(pwd string) (string, error) {
-	encrypt, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.MinCost)
+	return user.HashPassword(pwd, global.Conf.BcryptCost)
+}
+
+// sendVerifyEmail 发送邮箱验证邮件
+func sendVerifyEmail(u *user.User) error {
+	token, err := user.IssueToken(global.DB, u.ID, user.TokenVerifyEmail, 24*time.Hour)
 	if err != nil {
-		return "", err
+		return err
+	}
+	link := fmt.Sprintf("%s/verify_email?token=%s", global.Conf.SiteURL, token)
+	return global.Mailer.Send(u.Email, "LibLink 邮箱验证", "请在 24 小时内打开以下链接完成邮箱验证：\n"+link)
+}
+
+// VerifyEmail 验证邮箱
+func VerifyEmail(c *gin.Context) {
+	t, err := user.ConsumeToken(global.DB, user.TokenVerifyEmail, c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := global.DB.Model(&user.User{}).
+		Where("id = ? AND status = ?", t.UserID, user.StatusUnverified).
+		Update("status", user.StatusActive).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "verify successfully"})
+}
+
+// ResendVerifyEmail 重新发送验证邮件，无论邮箱是否存在都返回成功
+func ResendVerifyEmail(c *gin.Context) {
+	var msg message.EmailMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ? AND status = ?", msg.Email, user.StatusUnverified).First(&u).Error; err == nil {
+		if err := sendVerifyEmail(&u); err != nil {
+			global.Logger.Error("send verify email error: " + err.Error())
+		}
 	}
-	return string(encrypt), err
+
+	c.JSON(http.StatusOK, gin.H{"msg": "if the account exists, a verification email has been sent"})
+}
+
+// Invites 邀请列表
+func Invites(c *gin.Context) {
+	var invites []user.Invite
+	if err := global.DB.Order("id DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(invites),
+		"list":  invites,
+	})
+}
+
+// AddInvite 管理员发放注册邀请
+func AddInvite(c *gin.Context) {
+	var msg message.AddInviteMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+	if msg.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "邮箱不能为空"})
+		return
+	}
+	if msg.Role == "" {
+		msg.Role = user.RoleUser
+	}
+	if _, err := user.GetRole(global.DB, msg.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "角色不存在"})
+		return
+	}
+
+	var count int64
+	global.DB.Model(&user.User{}).Where("email = ?", msg.Email).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "账号已存在"})
+		return
+	}
+
+	token, hash, err := user.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成邀请码失败", "error": err.Error()})
+		return
+	}
+
+	invite := user.Invite{
+		Email:           msg.Email,
+		Role:            msg.Role,
+		PermissionGroup: msg.PermissionGroup,
+		InstNos:         strings.Join(branch.SplitInstNos(strings.Join(msg.InstNos, ",")), ","),
+		TokenHash:       hash,
+		CreatorID:       middleware.GetEmail(c),
+		ExpiresAt:       time.Now().Add(time.Duration(global.Conf.InviteExpireHour) * time.Hour),
+	}
+	if err := global.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "创建邀请失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "invite.add", "invite", invite.Email, gin.H{
+		"role":             invite.Role,
+		"permission_group": invite.PermissionGroup,
+		"inst_nos":         invite.InstNos,
+	})
+
+	link := fmt.Sprintf("%s/register?invite=%s", global.Conf.SiteURL, token)
+	if err := global.Mailer.Send(invite.Email, "LibLink 注册邀请", "您已受邀注册 LibLink，请打开以下链接完成注册：\n"+link); err != nil {
+		global.Logger.Error("send invite email error: " + err.Error())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "邀请创建成功",
+		"data":    invite,
+		"token":   token,
+	})
+}
+
+// DeleteInvite 撤销未使用的邀请
+func DeleteInvite(c *gin.Context) {
+	result := global.DB.Where("used_at IS NULL").Delete(&user.Invite{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "撤销邀请失败", "error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "邀请不存在或已使用"})
+		return
+	}
+
+	audit(c, "invite.delete", "invite", c.Param("id"), nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "邀请已撤销"})
 }
 
 // UsersSummary 用户简要汇总信息
@@ -118,3 +495,289 @@ func UsersSummary(c *gin.Context) {
 		"total": count,
 	})
 }
+
+// Users 用户列表，支持按关键字、角色、状态筛选
+func Users(c *gin.Context) {
+	type userRequest struct {
+		message.RequestMsg
+		Keyword string `json:"keyword" form:"keyword"`
+		Role    string `json:"role" form:"role"`
+		Status  string `json:"status" form:"status"`
+	}
+
+	var request userRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	db := global.DB.Model(&user.User{})
+	if request.Keyword != "" {
+		db = db.Where("username LIKE ? OR email LIKE ?", "%"+request.Keyword+"%", "%"+request.Keyword+"%")
+	}
+	if request.Role != "" {
+		db = db.Where("role = ?", request.Role)
+	}
+	if request.Status != "" {
+		db = db.Where("status = ?", request.Status)
+	}
+
+	var users []user.User
+	page, ok := paginate(c, &request.RequestMsg, db, "id", false, &users)
+	if !ok {
+		return
+	}
+
+	profiles := make([]user.Profile, 0, len(users))
+	for i := range users {
+		profiles = append(profiles, users[i].Profile())
+	}
+
+	response := gin.H{
+		"message": "获取用户列表成功",
+		"data":    profiles,
+	}
+	page.Fill(response)
+	c.JSON(http.StatusOK, response)
+}
+
+// findUser 根据路径参数 id 查找用户，失败时直接写入响应
+func findUser(c *gin.Context) (*user.User, bool) {
+	var u user.User
+	if err := global.DB.First(&u, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "用户不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return nil, false
+	}
+	return &u, true
+}
+
+// UserDetail 用户详情
+func UserDetail(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取用户成功",
+		"data":    u.Profile(),
+	})
+}
+
+// UpdateUser 修改用户角色、用户组与所属网点
+func UpdateUser(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+
+	var msg message.UpdateUserMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return
+	}
+	// 不能给自己提权，角色与所属网点分别需要角色管理与网点管理权限
+	if u.Email == currentUser.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不能修改自己的角色、用户组与所属网点"})
+		return
+	}
+
+	if !canManageUser(c, u) {
+		return
+	}
+
+	if _, err := user.GetRole(global.DB, msg.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "角色不存在"})
+		return
+	}
+	if msg.Role != u.Role && !canGrantRole(c, currentUser, msg.Role) {
+		return
+	}
+
+	instNos := branch.SplitInstNos(strings.Join(msg.InstNos, ","))
+	var count int64
+	global.DB.Model(&branch.Branch{}).Where("inst_no IN ?", instNos).Count(&count)
+	if int(count) != len(instNos) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "存在无效的网点编号"})
+		return
+	}
+	if strings.Join(instNos, ",") != u.InstNos && !user.HasPermission(global.DB, currentUser, user.PermBranchManage) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "修改所属网点需要网点管理权限"})
+		return
+	}
+
+	before := u.Profile()
+	updates := map[string]interface{}{
+		"role":             msg.Role,
+		"permission_group": msg.PermissionGroup,
+		"inst_nos":         strings.Join(instNos, ","),
+	}
+	if err := global.DB.Model(u).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新用户失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.update", "user", u.Email, gin.H{
+		"old": gin.H{"role": before.Role, "permission_group": before.PermissionGroup, "inst_nos": before.InstNos},
+		"new": updates,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "用户更新成功",
+		"data":    u.Profile(),
+	})
+}
+
+// canGrantRole 校验当前用户能否授予角色，需要角色管理权限，且只有管理员可以授予管理员角色
+// 不满足时直接写入响应
+func canGrantRole(c *gin.Context, currentUser *user.User, role string) bool {
+	if !user.HasPermission(global.DB, currentUser, user.PermRoleManage) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "修改角色需要角色管理权限"})
+		return false
+	}
+	if role == user.RoleAdmin && currentUser.Role != user.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"message": "只有管理员可以授予管理员角色"})
+		return false
+	}
+	return true
+}
+
+// canManageUser 校验当前用户能否管理目标用户，非管理员不能修改管理员账号，不满足时直接写入响应
+func canManageUser(c *gin.Context, target *user.User) bool {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+		return false
+	}
+	if !user.CanManage(currentUser, target) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "只有管理员可以管理管理员账号"})
+		return false
+	}
+	return true
+}
+
+// DisableUser 停用用户，已签发的登录令牌同时失效
+func DisableUser(c *gin.Context) {
+	setUserStatus(c, user.StatusDisabled)
+}
+
+// EnableUser 启用用户
+func EnableUser(c *gin.Context) {
+	setUserStatus(c, user.StatusActive)
+}
+
+func setUserStatus(c *gin.Context, status string) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+	if u.Email == middleware.GetEmail(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不能修改自己的状态"})
+		return
+	}
+	if !canManageUser(c, u) {
+		return
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status == user.StatusDisabled {
+		updates["token_version"] = u.TokenVersion + 1
+	}
+	old := u.Status
+	if err := global.DB.Model(u).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新用户状态失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.status", "user", u.Email, gin.H{"old": old, "new": status})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "用户状态更新成功",
+		"data":    u.Profile(),
+	})
+}
+
+// DeleteUser 删除用户
+func DeleteUser(c *gin.Context) {
+	u, ok := findUser(c)
+	if !ok {
+		return
+	}
+	if u.Email == middleware.GetEmail(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "不能删除自己"})
+		return
+	}
+	if !canManageUser(c, u) {
+		return
+	}
+
+	if err := global.DB.Delete(u).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "删除用户失败", "error": err.Error()})
+		return
+	}
+
+	audit(c, "user.delete", "user", u.Email, u.Profile())
+
+	c.JSON(http.StatusOK, gin.H{"message": "用户删除成功"})
+}
+
+// MyProfile 当前用户信息
+func MyProfile(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var permissions []string
+	if role, err := user.GetRole(global.DB, currentUser.Role); err == nil {
+		permissions = role.PermissionList()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "获取用户信息成功",
+		"data":        currentUser.Profile(),
+		"permissions": permissions,
+	})
+}
+
+// UpdateMyProfile 修改当前用户的基本信息，角色等权限信息只能由管理员修改
+func UpdateMyProfile(c *gin.Context) {
+	currentUser, err := middleware.CurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+		return
+	}
+
+	var msg message.UpdateProfileMsg
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "请求参数错误", "error": err.Error()})
+		return
+	}
+
+	// 外部账号的姓名每次登录时从认证系统同步
+	if currentUser.Source != user.SourceLocal {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "外部账号的用户名由认证系统维护"})
+		return
+	}
+
+	if err := global.DB.Model(currentUser).Update("username", msg.Username).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "更新用户信息失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "用户信息更新成功",
+		"data":    currentUser.Profile(),
+	})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"liblink/internal/models/archive"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLang 根据 Accept-Language 选择提示语言，默认中文
+func requestLang(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "zh"):
+			return "zh"
+		case strings.HasPrefix(tag, "en"):
+			return "en"
+		}
+	}
+	return "zh"
+}
+
+// validationFailed 返回本地化的档案校验错误
+func validationFailed(c *gin.Context, errs archive.ValidationErrors) {
+	lang := requestLang(c)
+	details := make([]gin.H, 0, len(errs))
+	for _, e := range errs {
+		detail := gin.H{"field": e.Field, "code": e.Code, "message": e.Message(lang)}
+		if e.Row > 0 {
+			detail["row"] = e.Row
+		}
+		details = append(details, detail)
+	}
+
+	msg := "档案数据校验失败"
+	if lang == "en" {
+		msg = "Archive validation failed"
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"message": msg, "errors": details})
+}
@@ -1,12 +1,19 @@
 package message
 
-import "github.com/gin-gonic/gin"
+import (
+	"liblink/internal/models/archive"
 
+	"github.com/gin-gonic/gin"
+)
+
+// RequestMsg 列表请求的公共参数，分页方式见 Paginate
 type RequestMsg struct {
 	DateStart string `json:"date_start" form:"date_start"`
 	DateEnd   string `json:"date_end" form:"date_end"`
-	Page      int    `json:"page" form:"page" default:"1"`
-	PageSize  int    `json:"page_size" form:"page_size" default:"20"`
+	Page      int    `json:"page" form:"page"` // 页码分页，仅为兼容保留，指定 cursor 时忽略
+	PageSize  int    `json:"page_size" form:"page_size"`
+	Cursor    string `json:"cursor" form:"cursor"`         // 上一页返回的 next_cursor
+	WithTotal bool   `json:"with_total" form:"with_total"` // 游标分页时是否返回总数
 }
 
 type GetQuestionMsg struct {
@@ -93,3 +100,144 @@ type DeletePaperMsg struct {
 type ExportPaperMsg struct {
 	ID string `json:"paper_id"`
 }
+
+type AddRoleMsg struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	RequireTOTP bool     `json:"require_totp"`
+}
+
+type AssignRoleMsg struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AddBranchMsg struct {
+	InstNo   string `json:"inst_no"`
+	Name     string `json:"name"`
+	ParentNo string `json:"parent_no"`
+}
+
+type AssignBranchMsg struct {
+	Email   string   `json:"email"`
+	InstNos []string `json:"inst_nos"`
+}
+
+type RegisterMsg struct {
+	InviteToken string `json:"invite_token"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+}
+
+type AddInviteMsg struct {
+	Email           string   `json:"email"`
+	Role            string   `json:"role"`
+	PermissionGroup string   `json:"permission_group"`
+	InstNos         []string `json:"inst_nos"`
+}
+
+type EmailMsg struct {
+	Email string `json:"email"`
+}
+
+type ChangePasswordMsg struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type ResetPasswordMsg struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type AdminResetPasswordMsg struct {
+	Email       string `json:"email"`
+	NewPassword string `json:"new_password"` // 为空时自动生成临时密码
+}
+
+type UpdateUserMsg struct {
+	Role            string   `json:"role"`
+	PermissionGroup string   `json:"permission_group"`
+	InstNos         []string `json:"inst_nos"`
+}
+
+type UpdateProfileMsg struct {
+	Username string `json:"username"`
+}
+
+type TwoFactorLoginMsg struct {
+	PreAuthToken string `json:"pre_auth_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TOTPCodeMsg struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+type RevealArchiveMsg struct {
+	Reason string `json:"reason"`
+}
+
+type ArchiveTypeMsg struct {
+	Code           string            `json:"code"`
+	Name           string            `json:"name"`
+	RetentionYears int               `json:"retention_years"`
+	FileNoTemplate string            `json:"file_no_template"`
+	Fields         archive.FieldDefs `json:"fields"`
+	Disabled       bool              `json:"disabled"`
+}
+
+type SavedSearchMsg struct {
+	Name        string        `json:"name"`
+	Q           string        `json:"q"`
+	Query       archive.Query `json:"query"`
+	Sort        string        `json:"sort"`
+	Order       string        `json:"order"`
+	SharedGroup string        `json:"shared_group"`
+}
+
+type LocationMsg struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Level    string `json:"level"`
+	ParentID uint   `json:"parent_id"`
+	Capacity int    `json:"capacity"`
+}
+
+type MoveLocationMsg struct {
+	ParentID uint   `json:"parent_id"`
+	Remark   string `json:"remark"`
+}
+
+type MoveArchivesMsg struct {
+	ArchiveIDs []uint `json:"archive_ids"`
+	LocationID uint   `json:"location_id"`
+	Remark     string `json:"remark"`
+}
+
+type LabelSheetMsg struct {
+	ArchiveIDs  []uint `json:"archive_ids"`
+	LocationIDs []uint `json:"location_ids"`
+	Field       string `json:"field"` // file_no 或 contract_no，默认 file_no
+}
+
+type DeskOperateMsg struct {
+	Operation  string   `json:"operation"` // borrow 或 return
+	BorrowerID string   `json:"borrower_id"`
+	Codes      []string `json:"codes"` // 扫描到的档案编号或合同编号
+	Remark     string   `json:"remark"`
+}
+
+type LoanRequestMsg struct {
+	ArchiveIDs []uint `json:"archive_ids"`
+	Remark     string `json:"remark"`
+}
+
+type SubscribeMsg struct {
+	NotifyNew    bool `json:"notify_new"`
+	NotifyBorrow bool `json:"notify_borrow"`
+}
@@ -0,0 +1,181 @@
+package message
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 每页条数
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// ErrInvalidCursor 游标无法解析、与当前排序方式不一致或对应的记录已不存在
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page 分页结果
+type Page struct {
+	Page       int    // 页码分页时的页码
+	PageSize   int    // 每页条数
+	NextCursor string // 下一页的游标，为空表示没有下一页
+	Total      *int64 // 未要求统计总数时为 nil
+}
+
+// Fill 将分页信息写入响应
+func (p *Page) Fill(h map[string]interface{}) {
+	h["page_size"] = p.PageSize
+	h["next_cursor"] = p.NextCursor
+	if p.Page > 0 {
+		h["page"] = p.Page
+	}
+	if p.Total != nil {
+		h["total"] = *p.Total
+	}
+}
+
+// cursor 只记录排序方式与上一页最后一条记录的ID，排序字段的值在查询时从数据库读取，不会经由游标泄露
+type cursor struct {
+	Column string `json:"c"`
+	Desc   bool   `json:"d"`
+	ID     uint   `json:"i"`
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Paginate 按 column、id 排序分页查询，结果写入 dest（模型切片的指针）
+//
+// 默认使用游标分页：首页不传 cursor，之后传上一页返回的 next_cursor，with_total=true 时额外统计总数。
+// 只传 page 时按 OFFSET 分页并统计总数，与旧的接口行为一致。
+// column 必须是调用方校验过的列名，每页条数超过 MaxPageSize 时按 MaxPageSize 返回。
+func (r *RequestMsg) Paginate(db *gorm.DB, column string, desc bool, dest interface{}) (*Page, error) {
+	size := r.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	page := &Page{PageSize: size}
+	legacy := r.Cursor == "" && r.Page > 0
+
+	if legacy || r.WithTotal {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	query := db.Session(&gorm.Session{})
+	switch {
+	case r.Cursor != "":
+		cur, err := decodeCursor(r.Cursor)
+		if err != nil || cur.Column != column || cur.Desc != desc {
+			return nil, ErrInvalidCursor
+		}
+		sql, args, err := after(db, dest, column, desc, cur.ID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(sql, args...)
+	case legacy:
+		page.Page = r.Page
+		query = query.Offset((r.Page - 1) * size)
+	}
+
+	if column != "id" {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+	tx := query.Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: desc}).Limit(size + 1).Find(dest)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// 多查询一条判断是否还有下一页
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() > size {
+		rows.Set(rows.Slice(0, size))
+		id, _ := tx.Statement.Schema.PrioritizedPrimaryField.ValueOf(tx.Statement.Context, rows.Index(size-1))
+		page.NextCursor = cursor{Column: column, Desc: desc, ID: toUint(id)}.encode()
+	}
+	return page, nil
+}
+
+// after 游标之后的记录的查询条件，排序字段可能为 NULL（升序时排在最前，降序时排在最后）
+func after(db *gorm.DB, dest interface{}, column string, desc bool, id uint) (string, []interface{}, error) {
+	if column == "id" {
+		return keyset(column, desc, false), []interface{}{id}, nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(dest); err != nil {
+		return "", nil, err
+	}
+	var values []interface{}
+	if err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Table(stmt.Schema.Table).
+		Where("id = ?", id).Limit(1).Pluck(column, &values).Error; err != nil {
+		return "", nil, err
+	}
+	if len(values) == 0 {
+		return "", nil, ErrInvalidCursor
+	}
+
+	value := values[0]
+	if value == nil {
+		return keyset(column, desc, true), []interface{}{id}, nil
+	}
+	return keyset(column, desc, false), []interface{}{value, value, id}, nil
+}
+
+// keyset 排在 (value, id) 之后的记录，null 表示游标记录的排序字段为 NULL
+func keyset(column string, desc, null bool) string {
+	if column == "id" {
+		if desc {
+			return "id < ?"
+		}
+		return "id > ?"
+	}
+	switch {
+	case null && desc:
+		return fmt.Sprintf("(%s IS NULL AND id < ?)", column)
+	case null:
+		return fmt.Sprintf("((%s IS NULL AND id > ?) OR %s IS NOT NULL)", column, column)
+	case desc:
+		return fmt.Sprintf("(%s < ? OR (%s = ? AND id < ?) OR %s IS NULL)", column, column, column)
+	default:
+		return fmt.Sprintf("(%s > ? OR (%s = ? AND id > ?))", column, column)
+	}
+}
+
+func toUint(v interface{}) uint {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(rv.Uint())
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package message
+
+import (
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	c := cursor{Column: "amount", Desc: true, ID: 42}
+	got, err := decodeCursor(c.encode())
+	if err != nil || got != c {
+		t.Errorf("decodeCursor() = %+v, %v", got, err)
+	}
+	for _, s := range []string{"", "!!", "e30", "bm90IGpzb24"} {
+		if _, err := decodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("decodeCursor(%q) = %v", s, err)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	cases := []struct {
+		column    string
+		desc, nul bool
+		want      string
+	}{
+		{"id", false, false, "id > ?"},
+		{"id", true, false, "id < ?"},
+		{"amount", false, false, "(amount > ? OR (amount = ? AND id > ?))"},
+		{"amount", true, false, "(amount < ? OR (amount = ? AND id < ?) OR amount IS NULL)"},
+		{"amount", false, true, "((amount IS NULL AND id > ?) OR amount IS NOT NULL)"},
+		{"amount", true, true, "(amount IS NULL AND id < ?)"},
+	}
+	for _, c := range cases {
+		if got := keyset(c.column, c.desc, c.nul); got != c.want {
+			t.Errorf("keyset(%q, %v, %v) = %q, want %q", c.column, c.desc, c.nul, got, c.want)
+		}
+	}
+}
+
+func TestPageFill(t *testing.T) {
+	total := int64(25)
+	h := map[string]interface{}{}
+	(&Page{PageSize: 10, NextCursor: "abc", Total: &total}).Fill(h)
+	if h["page_size"] != 10 || h["next_cursor"] != "abc" || h["total"] != int64(25) {
+		t.Errorf("Fill() = %v", h)
+	}
+	if _, ok := h["page"]; ok {
+		t.Error("Fill() should omit page for cursor pagination")
+	}
+}
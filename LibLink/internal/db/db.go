@@ -2,8 +2,6 @@ package db
 
 import (
 	"fmt"
-	"liblink/internal/models/archive"
-	"liblink/internal/models/system"
 	"liblink/internal/models/user"
 
 	"gorm.io/driver/mysql"
@@ -16,6 +14,7 @@ func InitDB(host string, username string, password string) (*gorm.DB, error) {
 	return initDB(dsn)
 }
 
+// initDB 只建立连接，表结构由 migrations 管理
 func initDB(dsn string) (*gorm.DB, error) {
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info), // 设置日志级别为 Info
@@ -24,17 +23,13 @@ func initDB(dsn string) (*gorm.DB, error) {
 		fmt.Printf("%t\n", err)
 		return nil, err
 	}
-	// 自动迁移
-	err = db.AutoMigrate(
-		&user.User{},
-		&system.Notification{},
-		&archive.Folder{},
-		&archive.Archive{},
-		&archive.ArchiveRecord{},
-	)
-	fmt.Printf("test db init\n")
-	if err != nil {
-		return nil, err
+	return db, nil
+}
+
+// Seed 初始化内置角色与初始管理员，需在迁移完成后执行
+func Seed(db *gorm.DB, adminEmail, adminPassword string, bcryptCost int) error {
+	if err := user.SeedRoles(db); err != nil {
+		return err
 	}
-	return db, err
+	return user.SeedAdmin(db, adminEmail, adminPassword, bcryptCost)
 }
@@ -0,0 +1,198 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// baseline 的表结构固定为引入版本化迁移时的模型，不随模型修改
+// 后续的表结构变更只能通过追加迁移完成
+
+type baselineUser struct {
+	gorm.Model
+	Username        string     `gorm:"column:username;comment:'用户名'"`
+	Password        string     `gorm:"column:password;comment:'密码'"`
+	Email           string     `gorm:"column:email;comment:'email/唯一标识符'"`
+	Role            string     `gorm:"column:role;comment:'角色名称,对应 roles 表';default:user"`
+	PermissionGroup string     `gorm:"column:permission_group;comment:'用户组权限,逗号分隔'"`
+	InstNos         string     `gorm:"column:inst_nos;comment:'所属网点编号,逗号分隔,包含其下级网点'"`
+	Status          string     `gorm:"column:status;size:16;comment:'用户状态';default:active"`
+	FailedLogins    int        `gorm:"column:failed_logins;comment:'连续登录失败次数'"`
+	LastFailedAt    *time.Time `gorm:"column:last_failed_at;comment:'最近一次登录失败时间'"`
+	LockedUntil     *time.Time `gorm:"column:locked_until;comment:'锁定截止时间'"`
+	TokenVersion    uint       `gorm:"column:token_version;comment:'令牌版本,递增后已签发的登录令牌全部失效'"`
+	MustChangePwd   bool       `gorm:"column:must_change_pwd;comment:'下次登录需修改密码'"`
+	TOTPSecret      string     `gorm:"column:totp_secret;comment:'两步验证密钥'"`
+	TOTPEnabled     bool       `gorm:"column:totp_enabled;comment:'是否已启用两步验证'"`
+	TOTPLastCounter uint64     `gorm:"column:totp_last_counter;comment:'最近一次使用的验证码周期,防止重放'"`
+	Source          string     `gorm:"column:source;size:16;comment:'账号来源';default:local"`
+	ExternalID      string     `gorm:"column:external_id;comment:'外部账号标识,如 LDAP DN'"`
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+type baselineRole struct {
+	gorm.Model
+	Name        string `gorm:"column:name;size:64;uniqueIndex;comment:'角色名称'"`
+	Description string `gorm:"column:description;comment:'角色描述'"`
+	Permissions string `gorm:"column:permissions;comment:'权限列表,逗号分隔'"`
+	BuiltIn     bool   `gorm:"column:built_in;comment:'是否为内置角色'"`
+	RequireTOTP bool   `gorm:"column:require_totp;comment:'是否强制两步验证'"`
+}
+
+func (baselineRole) TableName() string {
+	return "roles"
+}
+
+type baselineUserToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"column:user_id;index;comment:'用户ID'"`
+	Purpose   string     `gorm:"column:purpose;size:32;comment:'令牌用途'"`
+	TokenHash string     `gorm:"column:token_hash;size:64;uniqueIndex;comment:'令牌SHA-256'"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;comment:'过期时间'"`
+	UsedAt    *time.Time `gorm:"column:used_at;comment:'使用时间'"`
+}
+
+func (baselineUserToken) TableName() string {
+	return "user_tokens"
+}
+
+type baselineInvite struct {
+	gorm.Model
+	Email           string     `gorm:"column:email;comment:'受邀邮箱'"`
+	Role            string     `gorm:"column:role;comment:'注册后的角色'"`
+	PermissionGroup string     `gorm:"column:permission_group;comment:'注册后的用户组权限'"`
+	InstNos         string     `gorm:"column:inst_nos;comment:'注册后的所属网点'"`
+	TokenHash       string     `gorm:"column:token_hash;size:64;uniqueIndex;comment:'邀请码SHA-256'"`
+	CreatorID       string     `gorm:"column:creator_id;comment:'邀请人'"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;comment:'过期时间'"`
+	UsedAt          *time.Time `gorm:"column:used_at;comment:'使用时间'"`
+}
+
+func (baselineInvite) TableName() string {
+	return "invites"
+}
+
+type baselineLoginLog struct {
+	gorm.Model
+	UserID    uint   `gorm:"column:user_id;index;comment:'用户ID,账号不存在时为0'"`
+	Email     string `gorm:"column:email;comment:'登录邮箱'"`
+	IP        string `gorm:"column:ip;size:64;index:idx_ip_created;comment:'来源IP'"`
+	UserAgent string `gorm:"column:user_agent;comment:'User-Agent'"`
+	Success   bool   `gorm:"column:success;comment:'是否成功'"`
+	Reason    string `gorm:"column:reason;comment:'失败原因'"`
+}
+
+func (baselineLoginLog) TableName() string {
+	return "login_logs"
+}
+
+type baselineRecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"column:user_id;index;comment:'用户ID'"`
+	CodeHash string     `gorm:"column:code_hash;size:64;comment:'恢复码SHA-256'"`
+	UsedAt   *time.Time `gorm:"column:used_at;comment:'使用时间'"`
+}
+
+func (baselineRecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+type baselineBranch struct {
+	gorm.Model
+	InstNo   string `gorm:"column:inst_no;size:32;uniqueIndex;comment:'网点编号'"`
+	Name     string `gorm:"column:name;comment:'网点名称'"`
+	ParentNo string `gorm:"column:parent_no;size:32;index:idx_parent_no;comment:'上级网点编号,顶层为空'"`
+}
+
+func (baselineBranch) TableName() string {
+	return "branches"
+}
+
+type baselineNotification struct {
+	gorm.Model
+	Type    string `gorm:"column:type;comment:'类型：通知(Notify)，警告(Alert)'"`
+	Title   string `gorm:"column:title;comment:'标题'"`
+	Content string `gorm:"column:content;comment:'正文'"`
+}
+
+func (baselineNotification) TableName() string {
+	return "notifications"
+}
+
+type baselineAuditLog struct {
+	gorm.Model
+	OperatorID string `gorm:"column:operator_id;index;comment:'操作人'"`
+	Action     string `gorm:"column:action;size:64;index;comment:'操作类型'"`
+	TargetType string `gorm:"column:target_type;size:32;comment:'操作对象类型'"`
+	TargetID   string `gorm:"column:target_id;size:64;comment:'操作对象标识'"`
+	Detail     string `gorm:"column:detail;type:text;comment:'操作详情,JSON'"`
+}
+
+func (baselineAuditLog) TableName() string {
+	return "audit_logs"
+}
+
+type baselineFolder struct {
+	gorm.Model
+	Name            string `gorm:"column:name;comment:'文件夹名称'"`
+	Path            string `gorm:"column:path;comment:'文件夹路径'"`
+	ParentID        uint   `gorm:"column:parent_id;index:idx_parent_id;comment:'父文件夹ID'"`
+	CreatorID       string `gorm:"column:creator_id;comment:'创建者ID'"`
+	GroupPermission string `gorm:"column:group_permission;comment:'用户组权限,自动继承父文件夹权限,需要有其中所有权限才能够访问该文件夹'"`
+}
+
+func (baselineFolder) TableName() string {
+	return "folders"
+}
+
+// 姓名、身份证号为加密后的字符串，金额与日期的类型与 ConvertTypedColumns 转换的结果一致
+type baselineArchive struct {
+	gorm.Model
+	FileNo          string `gorm:"column:file_no;comment:'档案编号'"`
+	ContractNo      string `gorm:"column:contract_no;comment:'合同编号'"`
+	Title           string `gorm:"column:title;comment:'档案标题'"`
+	Name            string `gorm:"column:name;comment:'姓名,加密存储'"`
+	IDCard          string `gorm:"column:id_card;comment:'身份证号,加密存储'"`
+	IDCardIndex     string `gorm:"column:id_card_index;size:64;index;comment:'身份证号盲索引'"`
+	InstNo          string `gorm:"column:inst_no;comment:'网点编号'"`
+	Manager         string `gorm:"column:manager;comment:'管户客户经理'"`
+	Amount          string `gorm:"column:amount;type:DECIMAL(18,2);comment:'合同金额'"`
+	ArcType         string `gorm:"column:arc_type;comment:'文献类型'"`
+	BorrowState     string `gorm:"column:borrow_state;comment:'借阅状态'"`
+	FolderID        uint   `gorm:"column:folder_id;comment:'文件夹ID'"`
+	CreatorID       string `gorm:"column:creator_id;comment:'创建者ID'"`
+	StorageDate     string `gorm:"column:storage_date;type:DATE;index;comment:'入库日期'"`
+	GroupPermission string `gorm:"column:group_permission;comment:'用户组权限,自动继承父文件夹权限,需要有其中所有权限才能够访问该档案'"`
+}
+
+func (baselineArchive) TableName() string {
+	return "archives"
+}
+
+type baselineArchiveRecord struct {
+	gorm.Model
+	ContractNo  string `gorm:"column:contract_no;comment:'合同编号'"`
+	CreatorID   string `gorm:"column:creator_id;comment:'借阅人ID'"`
+	OperateType string `gorm:"column:operate_type;comment:'操作类型，借阅或归还'"`
+	OperateDate string `gorm:"column:operate_date;type:DATETIME(3);comment:'操作日期'"`
+}
+
+func (baselineArchiveRecord) TableName() string {
+	return "archive_records"
+}
+
+type baselineLegacyValue struct {
+	gorm.Model
+	Table  string `gorm:"column:table_name;size:64;comment:'来源表'"`
+	RowID  uint   `gorm:"column:row_id;comment:'来源行ID'"`
+	Column string `gorm:"column:column_name;size:64;comment:'来源字段'"`
+	Value  string `gorm:"column:value;comment:'原始值'"`
+}
+
+func (baselineLegacyValue) TableName() string {
+	return "legacy_values"
+}
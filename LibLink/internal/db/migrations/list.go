@@ -0,0 +1,444 @@
+package migrations
+
+import (
+	"fmt"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/location"
+	"liblink/internal/models/user"
+	"liblink/pkg/fieldcrypt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// All 全部迁移，只能在末尾追加，已发布的迁移不可修改
+// baseline 使用 baseline.go 中固定的表结构，不随模型变化；已有数据库执行迁移时表或字段可能已存在，需先用 Migrator 判断
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      baseline,
+	},
+	{
+		Version: 2,
+		Name:    "archive_file_no_sequence",
+		Up:      fileNoSequenceUp,
+		Down:    fileNoSequenceDown,
+	},
+	{
+		Version: 3,
+		Name:    "archive_types",
+		Up:      archiveTypesUp,
+		Down:    archiveTypesDown,
+	},
+	{
+		Version: 4,
+		Name:    "archive_attachments",
+		Up:      attachmentsUp,
+		Down:    attachmentsDown,
+	},
+	{
+		Version: 5,
+		Name:    "attachment_versions",
+		Up:      attachmentVersionsUp,
+		Down:    attachmentVersionsDown,
+	},
+	{
+		Version: 6,
+		Name:    "attachment_texts",
+		Up:      attachmentTextsUp,
+		Down:    attachmentTextsDown,
+	},
+	{
+		Version: 7,
+		Name:    "archive_search_index",
+		Up:      archiveSearchIndexUp,
+		Down:    archiveSearchIndexDown,
+	},
+	{
+		Version: 8,
+		Name:    "saved_searches",
+		Up:      savedSearchesUp,
+		Down:    savedSearchesDown,
+	},
+	{
+		Version: 9,
+		Name:    "locations",
+		Up:      locationsUp,
+		Down:    locationsDown,
+	},
+	{
+		Version: 10,
+		Name:    "loans",
+		Up:      loansUp,
+		Down:    loansDown,
+	},
+	{
+		Version: 11,
+		Name:    "loan_approval",
+		Up:      loanApprovalUp,
+		Down:    loanApprovalDown,
+	},
+	{
+		Version: 12,
+		Name:    "grant_archive_reveal",
+		Up:      grantArchiveRevealUp,
+	},
+	{
+		Version: 13,
+		Name:    "archive_contract_no_unique",
+		Up:      contractNoUniqueUp,
+		Down:    contractNoUniqueDown,
+	},
+	{
+		Version: 14,
+		Name:    "grant_location_manage",
+		Up:      grantLocationManageUp,
+	},
+	{
+		Version: 15,
+		Name:    "encrypt_pii",
+		Up:      encryptPIIUp,
+	},
+}
+
+// baseline 引入版本化迁移前的表结构
+// 已有数据库先将金额、日期字段转换为强类型，再补齐缺失的表与字段
+func baseline(tx *gorm.DB) error {
+	legacy, err := archive.ConvertTypedColumns(tx)
+	if err != nil {
+		return err
+	}
+	for _, v := range legacy {
+		log.Printf("unparsable legacy value %s.%s id=%d: %q", v.Table, v.Column, v.RowID, v.Value)
+	}
+
+	return tx.AutoMigrate(
+		&baselineUser{},
+		&baselineRole{},
+		&baselineUserToken{},
+		&baselineInvite{},
+		&baselineLoginLog{},
+		&baselineRecoveryCode{},
+		&baselineBranch{},
+		&baselineNotification{},
+		&baselineAuditLog{},
+		&baselineFolder{},
+		&baselineArchive{},
+		&baselineArchiveRecord{},
+		&baselineLegacyValue{},
+	)
+}
+
+// fileNoSequenceUp 档案编号改为按类型的序列分配，并建立 (arc_type, file_no) 唯一索引
+func fileNoSequenceUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := tx.AutoMigrate(&archive.ArchiveSequence{}); err != nil {
+		return err
+	}
+	// TEXT 字段无法建立索引，使用固定的 DDL，不随模型变化
+	if err := tx.Exec("ALTER TABLE archives MODIFY arc_type VARCHAR(64) COMMENT '文献类型', MODIFY file_no VARCHAR(64) COMMENT '档案编号'").Error; err != nil {
+		return err
+	}
+
+	if err := archive.InitSequences(tx); err != nil {
+		return err
+	}
+	changed, err := archive.RenumberDuplicateFileNos(tx)
+	if err != nil {
+		return err
+	}
+	for _, v := range changed {
+		log.Printf("renumbered duplicate file_no id=%d: %q", v.RowID, v.Value)
+	}
+
+	// 重复编号处理完成后才建立唯一索引
+	if !m.HasIndex(&archive.Archive{}, "idx_archives_type_file_no") {
+		return tx.Exec("CREATE UNIQUE INDEX idx_archives_type_file_no ON archives (arc_type, file_no)").Error
+	}
+	return nil
+}
+
+func fileNoSequenceDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasIndex(&archive.Archive{}, "idx_archives_type_file_no") {
+		if err := m.DropIndex(&archive.Archive{}, "idx_archives_type_file_no"); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&archive.ArchiveSequence{})
+}
+
+// archiveTypesUp 建立档案类型登记表，已有档案中出现过的类型自动登记
+func archiveTypesUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := tx.AutoMigrate(&archive.ArchiveType{}); err != nil {
+		return err
+	}
+	if !m.HasColumn(&archive.Archive{}, "Extra") {
+		if err := m.AddColumn(&archive.Archive{}, "Extra"); err != nil {
+			return err
+		}
+	}
+	return archive.SeedArchiveTypes(tx)
+}
+
+func archiveTypesDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasColumn(&archive.Archive{}, "Extra") {
+		if err := m.DropColumn(&archive.Archive{}, "Extra"); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&archive.ArchiveType{})
+}
+
+func attachmentsUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&archive.Attachment{})
+}
+
+// attachmentsDown 仅删除附件记录表，存储中的文件需手动清理
+func attachmentsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&archive.Attachment{})
+}
+
+// attachmentVersionsUp 建立附件版本表，已有附件作为第 1 个版本
+func attachmentVersionsUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := tx.AutoMigrate(&archive.AttachmentVersion{}); err != nil {
+		return err
+	}
+	if !m.HasColumn(&archive.Attachment{}, "Version") {
+		if err := m.AddColumn(&archive.Attachment{}, "Version"); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Exec("INSERT INTO attachment_versions (created_at, attachment_id, version, file_name, content_type, size, sha256, storage_key, uploader_id, corrupted) " +
+		"SELECT a.created_at, a.id, 1, a.file_name, a.content_type, a.size, a.sha256, a.storage_key, a.uploader_id, FALSE FROM attachments a " +
+		"WHERE a.deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM attachment_versions v WHERE v.attachment_id = a.id)").Error; err != nil {
+		return err
+	}
+	return tx.Exec("UPDATE attachments SET version = 1 WHERE version = 0").Error
+}
+
+func attachmentVersionsDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasColumn(&archive.Attachment{}, "Version") {
+		if err := m.DropColumn(&archive.Attachment{}, "Version"); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&archive.AttachmentVersion{})
+}
+
+// attachmentTextsUp 建立 OCR 结果表及 ngram 全文索引，已有附件加入识别队列
+func attachmentTextsUp(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&archive.AttachmentText{}); err != nil {
+		return err
+	}
+	if !tx.Migrator().HasIndex(&archive.AttachmentText{}, "idx_attachment_texts_content") {
+		if err := tx.Exec("CREATE FULLTEXT INDEX idx_attachment_texts_content ON attachment_texts (content) WITH PARSER ngram").Error; err != nil {
+			return err
+		}
+	}
+	return tx.Exec("INSERT INTO attachment_texts (attachment_id, archive_id, version, status, content, error, updated_at) "+
+		"SELECT a.id, a.archive_id, a.version, ?, '', '', NOW() FROM attachments a "+
+		"WHERE a.deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM attachment_texts t WHERE t.attachment_id = a.id)", archive.OCRPending).Error
+}
+
+func attachmentTextsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&archive.AttachmentText{})
+}
+
+// archiveSearchIndexUp 档案检索使用的 ngram 全文索引，姓名加密存储不纳入索引
+func archiveSearchIndexUp(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(&archive.Archive{}, "idx_archives_search") {
+		return nil
+	}
+	return tx.Exec("CREATE FULLTEXT INDEX idx_archives_search ON archives (title, contract_no, manager, file_no) WITH PARSER ngram").Error
+}
+
+func archiveSearchIndexDown(tx *gorm.DB) error {
+	if !tx.Migrator().HasIndex(&archive.Archive{}, "idx_archives_search") {
+		return nil
+	}
+	return tx.Migrator().DropIndex(&archive.Archive{}, "idx_archives_search")
+}
+
+func savedSearchesUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(&archive.SavedSearch{}, &archive.Subscription{})
+}
+
+func savedSearchesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&archive.Subscription{}, &archive.SavedSearch{})
+}
+
+// locationsUp 纸质档案存放位置与移动记录，档案增加所在档案盒
+func locationsUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := tx.AutoMigrate(&location.Location{}, &location.Movement{}); err != nil {
+		return err
+	}
+	if !m.HasColumn(&archive.Archive{}, "LocationID") {
+		if err := m.AddColumn(&archive.Archive{}, "LocationID"); err != nil {
+			return err
+		}
+	}
+	if !m.HasIndex(&archive.Archive{}, "LocationID") {
+		return m.CreateIndex(&archive.Archive{}, "LocationID")
+	}
+	return nil
+}
+
+func locationsDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasColumn(&archive.Archive{}, "LocationID") {
+		if err := m.DropColumn(&archive.Archive{}, "LocationID"); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&location.Movement{}, &location.Location{})
+}
+
+// loansUp 柜台扫码借还批次，借阅记录关联到批次
+func loansUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if err := tx.AutoMigrate(&archive.Loan{}); err != nil {
+		return err
+	}
+	if !m.HasColumn(&archive.ArchiveRecord{}, "LoanID") {
+		if err := m.AddColumn(&archive.ArchiveRecord{}, "LoanID"); err != nil {
+			return err
+		}
+	}
+	if !m.HasIndex(&archive.ArchiveRecord{}, "LoanID") {
+		return m.CreateIndex(&archive.ArchiveRecord{}, "LoanID")
+	}
+	return nil
+}
+
+func loansDown(tx *gorm.DB) error {
+	m := tx.Migrator()
+	if m.HasColumn(&archive.ArchiveRecord{}, "LoanID") {
+		if err := m.DropColumn(&archive.ArchiveRecord{}, "LoanID"); err != nil {
+			return err
+		}
+	}
+	return m.DropTable(&archive.Loan{})
+}
+
+// loanApprovalUp 借阅申请审批，已有的柜台批次视为已通过，内置 manager 角色补充 loan.approve 权限
+func loanApprovalUp(tx *gorm.DB) error {
+	m := tx.Migrator()
+	for _, field := range []string{"Status", "ApproverID"} {
+		if !m.HasColumn(&archive.Loan{}, field) {
+			if err := m.AddColumn(&archive.Loan{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	if !m.HasIndex(&archive.Loan{}, "Status") {
+		if err := m.CreateIndex(&archive.Loan{}, "Status"); err != nil {
+			return err
+		}
+	}
+	if err := tx.Exec("UPDATE loans SET status = ? WHERE status IS NULL OR status = ''", archive.LoanApproved).Error; err != nil {
+		return err
+	}
+	if err := tx.AutoMigrate(&archive.LoanItem{}); err != nil {
+		return err
+	}
+	return user.GrantPermissions(tx, user.RoleManager, user.PermLoanApprove)
+}
+
+// loanApprovalDown 从全部角色中收回 loan.approve，待审批的申请随表一并删除
+func loanApprovalDown(tx *gorm.DB) error {
+	if err := user.RevokePermission(tx, user.PermLoanApprove); err != nil {
+		return err
+	}
+	m := tx.Migrator()
+	for _, field := range []string{"ApproverID", "Status"} {
+		if m.HasColumn(&archive.Loan{}, field) {
+			if err := m.DropColumn(&archive.Loan{}, field); err != nil {
+				return err
+			}
+		}
+	}
+	return m.DropTable(&archive.LoanItem{})
+}
+
+// grantArchiveRevealUp 已有部署中的 auditor、manager 内置角色补充 archive.reveal 权限
+func grantArchiveRevealUp(tx *gorm.DB) error {
+	for _, role := range []string{user.RoleAuditor, user.RoleManager} {
+		if err := user.GrantPermissions(tx, role, user.PermArchiveReveal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contractNoUniqueUp 合同编号建立唯一索引，历史空值改为 NULL
+// 存在重复的合同编号时逐条输出并中止，人工处理后重新执行
+func contractNoUniqueUp(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex(&archive.Archive{}, "idx_archives_contract_no") {
+		return nil
+	}
+	if err := tx.Exec("UPDATE archives SET contract_no = NULL WHERE contract_no = ''").Error; err != nil {
+		return err
+	}
+
+	var duplicates []struct {
+		ContractNo string
+		Count      int64
+	}
+	if err := tx.Table("archives").Select("contract_no, COUNT(*) AS count").Where("contract_no IS NOT NULL").
+		Group("contract_no").Having("COUNT(*) > 1").Scan(&duplicates).Error; err != nil {
+		return err
+	}
+	for _, d := range duplicates {
+		log.Printf("duplicate contract_no %q: %d archives", d.ContractNo, d.Count)
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%d duplicate contract_no values, resolve them and migrate again", len(duplicates))
+	}
+
+	// TEXT 字段无法建立索引
+	if err := tx.Exec("ALTER TABLE archives MODIFY contract_no VARCHAR(128) NULL COMMENT '合同编号'").Error; err != nil {
+		return err
+	}
+	return tx.Exec("CREATE UNIQUE INDEX idx_archives_contract_no ON archives (contract_no)").Error
+}
+
+func contractNoUniqueDown(tx *gorm.DB) error {
+	if !tx.Migrator().HasIndex(&archive.Archive{}, "idx_archives_contract_no") {
+		return nil
+	}
+	return tx.Migrator().DropIndex(&archive.Archive{}, "idx_archives_contract_no")
+}
+
+// grantLocationManageUp 已有部署中的 clerk 内置角色补充 location.manage 权限
+func grantLocationManageUp(tx *gorm.DB) error {
+	return user.GrantPermissions(tx, user.RoleClerk, user.PermLocationManage)
+}
+
+// encryptPIIUp 加密历史明文的姓名、身份证号并补齐盲索引，同时加密两步验证密钥
+// 未配置密钥环时敏感字段以明文保存，按明文查询，无需处理；之后配置或轮换密钥需执行 encrypt-pii
+// 加密后无法回滚为明文，不提供 Down
+func encryptPIIUp(tx *gorm.DB) error {
+	if fieldcrypt.Default() == nil {
+		log.Printf("encrypt pii skipped, no keyring configured")
+		return nil
+	}
+	n, err := archive.EncryptPII(tx, 500)
+	if err != nil {
+		return err
+	}
+	log.Printf("encrypt pii done, %d archives updated", n)
+	n, err = user.EncryptTOTPSecrets(tx)
+	if err != nil {
+		return err
+	}
+	log.Printf("encrypt totp secrets done, %d users updated", n)
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package migrations 版本化的数据库结构迁移，按版本号顺序执行并记录在 schema_migrations 表中
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次结构迁移，Down 为 nil 表示不可回滚
+// MySQL 的 DDL 无法在事务中回滚，Up/Down 应当可以安全地重复执行
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   uint      `gorm:"column:version;primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"column:name;size:128" json:"name"`
+	AppliedAt time.Time `gorm:"column:applied_at" json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// ErrPending 存在未执行的迁移
+var ErrPending = errors.New("数据库结构未迁移，请先执行 liblink migrate up")
+
+func applied(DB *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := DB.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	var records []SchemaMigration
+	if err := DB.Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[uint]SchemaMigration, len(records))
+	for _, r := range records {
+		done[r.Version] = r
+	}
+	return done, nil
+}
+
+// Up 按顺序执行全部未执行的迁移，返回本次执行的迁移
+func Up(DB *gorm.DB) ([]Migration, error) {
+	done, err := applied(DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, m := range All {
+		if _, ok := done[m.Version]; ok {
+			continue
+		}
+		if err := m.Up(DB); err != nil {
+			return ran, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		record := SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		if err := DB.Create(&record).Error; err != nil {
+			return ran, err
+		}
+		ran = append(ran, m)
+	}
+	return ran, nil
+}
+
+// Down 回滚最近执行的 steps 个迁移
+func Down(DB *gorm.DB, steps int) ([]Migration, error) {
+	done, err := applied(DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(All) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		m := All[i]
+		if _, ok := done[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return rolledBack, fmt.Errorf("migration %d %s 不可回滚", m.Version, m.Name)
+		}
+		if err := m.Down(DB); err != nil {
+			return rolledBack, fmt.Errorf("rollback %d %s: %w", m.Version, m.Name, err)
+		}
+		if err := DB.Delete(&SchemaMigration{}, m.Version).Error; err != nil {
+			return rolledBack, err
+		}
+		rolledBack = append(rolledBack, m)
+	}
+	return rolledBack, nil
+}
+
+// List 全部迁移及其执行状态
+func List(DB *gorm.DB) ([]Status, error) {
+	done, err := applied(DB)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, 0, len(All))
+	for _, m := range All {
+		s := Status{Version: m.Version, Name: m.Name}
+		if r, ok := done[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &r.AppliedAt
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Check 启动前检查数据库结构是否为最新版本
+func Check(DB *gorm.DB) error {
+	list, err := List(DB)
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		if !s.Applied {
+			return fmt.Errorf("%w (pending: %d %s)", ErrPending, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// validate 检查迁移版本号严格递增
+func validate(list []Migration) error {
+	var last uint
+	for _, m := range list {
+		if m.Version <= last {
+			return fmt.Errorf("migration %d %s: version must be greater than %d", m.Version, m.Name, last)
+		}
+		if m.Up == nil {
+			return fmt.Errorf("migration %d %s: missing Up", m.Version, m.Name)
+		}
+		last = m.Version
+	}
+	return nil
+}
+
+func init() {
+	if err := validate(All); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAllMigrationsValid(t *testing.T) {
+	if err := validate(All); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRejectsOutOfOrder(t *testing.T) {
+	up := func(tx *gorm.DB) error { return nil }
+	list := []Migration{
+		{Version: 1, Name: "a", Up: up},
+		{Version: 3, Name: "b", Up: up},
+		{Version: 2, Name: "c", Up: up},
+	}
+	if err := validate(list); err == nil {
+		t.Fatal("out of order versions should be rejected")
+	}
+	if err := validate([]Migration{{Version: 1, Name: "a"}}); err == nil {
+		t.Fatal("missing Up should be rejected")
+	}
+}
@@ -3,20 +3,35 @@ package global
 import (
 	"fmt"
 	"liblink/config"
+	"liblink/internal/auth"
 	"liblink/internal/db"
+	"liblink/internal/models/archive"
+	"liblink/internal/search"
+	"liblink/pkg/fieldcrypt"
+	"liblink/pkg/mailer"
+	"liblink/pkg/ocr"
+	"liblink/pkg/storage"
 	"os"
+	"os/exec"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 var (
-	Env     string       = "main" // 工作环境（main, test） TODO 之后得整合到环境变量中
-	WorkDir string                // 工作路径
-	Conf    *config.Conf          // 配置文件
-	DB      *gorm.DB              // 数据库连接
-	JWTKey  string                // JWT密钥
-	Logger  *zap.Logger           // 全局日志
+	Env     string        = "main" // 工作环境（main, test） TODO 之后得整合到环境变量中
+	WorkDir string                 // 工作路径
+	Conf    *config.Conf           // 配置文件
+	DB      *gorm.DB               // 数据库连接
+	JWTKey  string                 // JWT密钥
+	Logger  *zap.Logger            // 全局日志
+	Mailer  mailer.Mailer          // 邮件发送
+
+	Authenticators []auth.Authenticator // 登录认证后端
+	OIDC           *auth.OIDCProvider   // 单点登录，未启用时为 nil
+	Storage        storage.Storage      // 档案附件存储
+	OCR            ocr.Engine           // 附件文字识别，未启用时为 nil
+	Search         search.Index         // 档案检索索引
 )
 
 func init() {
@@ -25,4 +40,93 @@ func init() {
 	Conf, _ = config.FromYaml(fmt.Sprintf("%s/%s-", WorkDir, Env))
 	JWTKey = Conf.JWTKey
 	DB, _ = db.InitDB(Conf.DatabaseHost, Conf.DatabaseUser, Conf.DatabasePassword)
+
+	if err := archive.ValidateFileNoTemplate(Conf.FileNoTemplate); err != nil {
+		Logger.Fatal(err.Error())
+	}
+
+	var err error
+	if Authenticators, err = auth.FromConfig(Conf); err != nil {
+		Logger.Fatal("init auth backends error: " + err.Error())
+	}
+
+	if Conf.Encryption.Enabled() {
+		keyring, err := fieldcrypt.NewKeyringFromBase64(Conf.Encryption.PrimaryKey, Conf.Encryption.Keys, Conf.Encryption.IndexKey)
+		if err != nil {
+			Logger.Fatal("init field encryption error: " + err.Error())
+		}
+		fieldcrypt.SetDefault(keyring)
+	} else {
+		Logger.Warn("field encryption is not configured, personal data is stored in plain text")
+	}
+
+	if Conf.OIDC.Enabled {
+		OIDC = auth.NewOIDCProvider(Conf.OIDC)
+	}
+
+	switch Conf.Storage.Backend {
+	case "local":
+		Storage = &storage.Local{Root: Conf.Storage.LocalRoot}
+	case "s3":
+		Storage = &storage.S3{
+			Endpoint:  Conf.Storage.S3.Endpoint,
+			Region:    Conf.Storage.S3.Region,
+			Bucket:    Conf.Storage.S3.Bucket,
+			AccessKey: Conf.Storage.S3.AccessKey,
+			SecretKey: Conf.Storage.S3.SecretKey,
+			PathStyle: Conf.Storage.S3.PathStyle,
+		}
+	default:
+		Logger.Fatal("unknown storage backend: " + Conf.Storage.Backend)
+	}
+
+	switch Conf.OCR.Engine {
+	case "tesseract":
+		if _, err := exec.LookPath(Conf.OCR.Command); err != nil {
+			Logger.Warn("tesseract not found, ocr disabled: " + err.Error())
+			break
+		}
+		OCR = &ocr.Tesseract{
+			Command:    Conf.OCR.Command,
+			PDFCommand: Conf.OCR.PDFCommand,
+			Lang:       Conf.OCR.Lang,
+			DPI:        Conf.OCR.DPI,
+		}
+	case "none", "":
+	default:
+		Logger.Fatal("unknown ocr engine: " + Conf.OCR.Engine)
+	}
+
+	switch Conf.Search.Engine {
+	case "mysql":
+		Search = search.MySQL{}
+	case "bleve":
+		index, err := search.OpenBleve(Conf.Search.BlevePath)
+		if err != nil {
+			Logger.Fatal("open search index error: " + err.Error())
+		}
+		index.OnError = func(err error) {
+			Logger.Error("sync search index error: " + err.Error())
+		}
+		if DB != nil {
+			if err := index.Register(DB); err != nil {
+				Logger.Fatal("register search index error: " + err.Error())
+			}
+		}
+		Search = index
+	default:
+		Logger.Fatal("unknown search engine: " + Conf.Search.Engine)
+	}
+
+	if Conf.Mail.SMTPHost != "" {
+		Mailer = &mailer.SMTPMailer{
+			Host:     Conf.Mail.SMTPHost,
+			Port:     Conf.Mail.SMTPPort,
+			Username: Conf.Mail.Username,
+			Password: Conf.Mail.Password,
+			From:     Conf.Mail.From,
+		}
+	} else {
+		Mailer = &mailer.LogMailer{Logger: Logger}
+	}
 }
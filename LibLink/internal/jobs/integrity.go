@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"liblink/internal/global"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/system"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// VerifyAttachments 重新计算全部附件版本的校验和，发现文件缺失或内容不一致时发布 Alert 通知
+func VerifyAttachments(ctx context.Context) error {
+	failures, checked, err := archive.VerifyAttachments(ctx, global.DB, global.Storage, 100)
+	if len(failures) > 0 {
+		if nerr := global.DB.Create(integrityAlert(failures)).Error; nerr != nil {
+			global.Logger.Error("add integrity alert error: " + nerr.Error())
+		}
+	}
+	global.Logger.Info("attachment integrity check done", zap.Int("checked", checked), zap.Int("failures", len(failures)))
+	return err
+}
+
+func integrityAlert(failures []archive.IntegrityFailure) *system.Notification {
+	lines := make([]string, 0, len(failures))
+	for _, f := range failures {
+		v := f.Version
+		switch f.Reason {
+		case archive.IntegrityMissing:
+			lines = append(lines, fmt.Sprintf("附件 %d 第 %d 版（%s）：存储中的文件缺失", v.AttachmentID, v.Version, v.FileName))
+		default:
+			lines = append(lines, fmt.Sprintf("附件 %d 第 %d 版（%s）：校验和不一致，记录值 %s，实际值 %s", v.AttachmentID, v.Version, v.FileName, v.SHA256, f.Actual))
+		}
+	}
+	return &system.Notification{
+		Type:    system.NotificationAlert,
+		Title:   fmt.Sprintf("附件完整性校验发现 %d 个异常文件", len(failures)),
+		Content: strings.Join(lines, "\n"),
+	}
+}
@@ -0,0 +1,26 @@
+// Package jobs 后台定时任务
+package jobs
+
+import (
+	"context"
+	"liblink/internal/global"
+	"time"
+)
+
+// Every 在后台按固定间隔执行任务，直到 ctx 取消，任务出错只记录日志
+func Every(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					global.Logger.Error("job " + name + " error: " + err.Error())
+				}
+			}
+		}
+	}()
+}
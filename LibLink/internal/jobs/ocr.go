@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"liblink/internal/global"
+	"liblink/internal/models/archive"
+	"liblink/pkg/ocr"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ocrWake = make(chan struct{}, 1)
+
+// WakeOCR 通知识别任务有新的附件待识别
+func WakeOCR() {
+	select {
+	case ocrWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartOCR 启动附件文字识别，收到 WakeOCR 通知时立即处理，同时每分钟检查一次队列
+func StartOCR(ctx context.Context, engine ocr.Engine, workers int, timeout time.Duration) {
+	if err := archive.ResetOCRTasks(global.DB); err != nil {
+		global.Logger.Error("reset ocr tasks error: " + err.Error())
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+			for {
+				for {
+					ok, err := recognizeNext(ctx, engine, timeout)
+					if err != nil {
+						global.Logger.Error("ocr task error: " + err.Error())
+					}
+					if !ok || err != nil {
+						break
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ocrWake:
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// recognizeNext 识别队列中的一个附件，队列为空时返回 false
+func recognizeNext(ctx context.Context, engine ocr.Engine, timeout time.Duration) (bool, error) {
+	task, err := archive.ClaimOCRTask(global.DB)
+	if err != nil || task == nil {
+		return false, err
+	}
+
+	var version archive.AttachmentVersion
+	var text string
+	err = global.DB.Where("attachment_id = ? AND version = ?", task.AttachmentID, task.Version).First(&version).Error
+	if err == nil {
+		text, err = recognize(ctx, engine, &version, timeout)
+	}
+	if err != nil {
+		global.Logger.Warn("ocr attachment failed", zap.Uint("attachment_id", task.AttachmentID), zap.Int("version", task.Version), zap.Error(err))
+	}
+	return true, archive.FinishOCRTask(global.DB, task, text, err)
+}
+
+// recognize 将附件下载到临时文件后交给识别引擎
+func recognize(ctx context.Context, engine ocr.Engine, v *archive.AttachmentVersion, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	r, err := global.Storage.Get(ctx, v.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "liblink-ocr-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return engine.Recognize(ctx, f.Name(), v.ContentType)
+}
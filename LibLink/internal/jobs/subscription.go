@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"liblink/internal/global"
+	"liblink/internal/models/archive"
+	"liblink/internal/models/branch"
+	"liblink/internal/models/user"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxListed 通知邮件中每类变化最多列出的档案数
+const maxListed = 50
+
+// NotifySubscriptions 检查全部订阅，上次检查之后有新档案匹配或匹配档案借阅状态变化时邮件通知订阅者
+// 按订阅者本人的权限与网点范围筛选，不会通知其无权查看的档案
+func NotifySubscriptions(ctx context.Context) error {
+	var subs []archive.Subscription
+	if err := global.DB.Order("id").Find(&subs).Error; err != nil {
+		return err
+	}
+
+	for i := range subs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := notifySubscription(ctx, &subs[i], now); err != nil {
+			// 单个订阅失败不影响其他订阅，下次检查时重试
+			global.Logger.Error(fmt.Sprintf("notify subscription %d error: %s", subs[i].ID, err.Error()))
+			continue
+		}
+		if err := global.DB.Model(&subs[i]).UpdateColumn("checked_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notifySubscription(ctx context.Context, sub *archive.Subscription, now time.Time) error {
+	var s archive.SavedSearch
+	var u user.User
+	if err := global.DB.First(&s, sub.SavedSearchID).Error; err != nil {
+		return err
+	}
+	if err := global.DB.Where("email = ?", sub.UserID).First(&u).Error; err != nil {
+		return err
+	}
+	// 停用的用户或已不再共享给订阅者的查询不再通知
+	if u.Status == user.StatusDisabled {
+		return nil
+	}
+	var visible int64
+	if err := global.DB.Model(&archive.SavedSearch{}).Scopes(archive.VisibleTo(u.Email, u.PermissionGroup)).Where("id = ?", s.ID).Count(&visible).Error; err != nil {
+		return err
+	}
+	if visible == 0 {
+		return nil
+	}
+
+	v := &archive.Validator{}
+	filter, err := s.Query.Scope(global.DB, v)
+	if err != nil {
+		return err
+	}
+	if !v.Valid() {
+		return fmt.Errorf("saved search %d is no longer valid", s.ID)
+	}
+	match, err := global.Search.Scope(ctx, s.Q)
+	if err != nil {
+		return err
+	}
+	instNos, all, err := branch.AccessibleInstNos(global.DB, &u)
+	if err != nil {
+		return err
+	}
+	matching := func() *gorm.DB {
+		return global.DB.Model(&archive.Archive{}).Where("group_permission = ?", u.PermissionGroup).
+			Scopes(archive.InBranches(instNos, all), filter, match)
+	}
+
+	var added, changed []archive.Archive
+	if sub.NotifyNew {
+		if err := matching().Where("created_at > ? AND created_at <= ?", sub.CheckedAt, now).
+			Order("id").Limit(maxListed + 1).Find(&added).Error; err != nil {
+			return err
+		}
+	}
+	if sub.NotifyBorrow {
+		if err := matching().Where("contract_no IN (SELECT contract_no FROM archive_records WHERE created_at > ? AND created_at <= ?)", sub.CheckedAt, now).
+			Order("id").Limit(maxListed + 1).Find(&changed).Error; err != nil {
+			return err
+		}
+	}
+	if len(added) == 0 && len(changed) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "您订阅的查询「%s」自 %s 以来有以下变化：\n", s.Name, sub.CheckedAt.Format("2006-01-02 15:04"))
+	writeArchives(&b, "新增档案", added, false)
+	writeArchives(&b, "借阅状态变化", changed, true)
+	return global.Mailer.Send(u.Email, "LibLink 订阅通知："+s.Name, b.String())
+}
+
+// writeArchives 列出档案编号、合同编号与标题，不包含姓名等敏感字段
+func writeArchives(b *strings.Builder, title string, archives []archive.Archive, withState bool) {
+	if len(archives) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n%s：\n", title)
+	for i, a := range archives {
+		if i == maxListed {
+			fmt.Fprintf(b, "  仅列出前 %d 个，请登录系统查看全部\n", maxListed)
+			break
+		}
+		line := fmt.Sprintf("  %s  %s  %s", a.FileNo, a.ContractNo, a.Title)
+		if withState {
+			line += "  当前状态：" + a.BorrowState
+		}
+		b.WriteString(line + "\n")
+	}
+}
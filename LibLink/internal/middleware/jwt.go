@@ -3,8 +3,8 @@ package middleware
 import (
 	"context"
 	"errors"
-	"fmt"
 	"liblink/internal/global"
+	"liblink/internal/models/user"
 	"net/http"
 	"strings"
 	"time"
@@ -16,8 +16,19 @@ import (
 const Issuer = "abing"
 const TokenExpireDuration = time.Hour * 24
 
+// 令牌用途，预认证令牌只能用于完成两步验证
+const (
+	SubjectAuthorization = "authorization"
+	SubjectPreAuth       = "pre_auth"
+	SubjectOIDCState     = "oidc_state"
+)
+
+const PreAuthExpireDuration = time.Minute * 5
+const OIDCStateExpireDuration = time.Minute * 10
+
 type JWTClaim struct {
-	Email string `json:"email"`
+	Email   string `json:"email"`
+	Version uint   `json:"ver"` // 对应用户的 TokenVersion，修改密码后旧令牌失效
 	jwt.StandardClaims
 }
 
@@ -25,12 +36,57 @@ func MakeClaimsToken(claims JWTClaim) (string, error) {
 	claims.Issuer = Issuer
 	claims.IssuedAt = time.Now().Unix()
 	claims.ExpiresAt = time.Now().Add(TokenExpireDuration).Unix()
-	claims.Subject = "authorization"
+	claims.Subject = SubjectAuthorization
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(global.JWTKey))
 	return tokenString, err
 }
 
+// MakePreAuthToken 密码校验通过但尚未完成两步验证时签发的短期令牌
+func MakePreAuthToken(claims JWTClaim) (string, error) {
+	claims.Issuer = Issuer
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = time.Now().Add(PreAuthExpireDuration).Unix()
+	claims.Subject = SubjectPreAuth
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(global.JWTKey))
+}
+
+// OIDCStateClaim 单点登录跳转前保存的 state、nonce 与 PKCE code_verifier
+type OIDCStateClaim struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+// MakeOIDCStateToken 签名后写入 Cookie，回调时校验以防止 CSRF
+func MakeOIDCStateToken(claims OIDCStateClaim) (string, error) {
+	claims.Issuer = Issuer
+	claims.IssuedAt = time.Now().Unix()
+	claims.ExpiresAt = time.Now().Add(OIDCStateExpireDuration).Unix()
+	claims.Subject = SubjectOIDCState
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(global.JWTKey))
+}
+
+func ParseOIDCStateToken(tokenStr string) (*OIDCStateClaim, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &OIDCStateClaim{}, func(token *jwt.Token) (i interface{}, err error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(global.JWTKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*OIDCStateClaim); ok && token.Valid && claims.Subject == SubjectOIDCState {
+		return claims, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
 // ParseClaimsToken Token解签
 func ParseClaimsToken(tokenStr string) (*JWTClaim, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaim{}, func(token *jwt.Token) (i interface{}, err error) {
@@ -38,8 +94,7 @@ func ParseClaimsToken(tokenStr string) (*JWTClaim, error) {
 	})
 
 	if err != nil {
-		fmt.Printf("%+v\n", token.Claims.(*JWTClaim))
-		fmt.Println(err.Error())
+		global.Logger.Debug("parse token error: " + err.Error())
 		return nil, err
 	}
 
@@ -55,6 +110,9 @@ type contextKey string
 
 const ContextEmailKey contextKey = "email"
 
+// ChangePasswordPath 需要修改密码的用户只能访问该接口
+const ChangePasswordPath = "/api/users/me/password"
+
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.Method == "OPTIONS" {
@@ -80,16 +138,37 @@ func JWTAuth() gin.HandlerFunc {
 		}
 
 		claims, err := ParseClaimsToken(parts[1])
-		if err != nil {
+		if err != nil || claims.Subject != SubjectAuthorization {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"message": "无效的token",
 			})
 			c.Abort()
 			return
 		}
+		// 校验令牌版本，修改或重置密码后旧令牌失效
+		var u user.User
+		if err := global.DB.Where("email = ?", claims.Email).First(&u).Error; err != nil ||
+			u.TokenVersion != claims.Version || u.Status == user.StatusDisabled {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": "登录已失效",
+			})
+			c.Abort()
+			return
+		}
+
+		if u.MustChangePwd && c.FullPath() != ChangePasswordPath {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message":              "请先修改密码",
+				"must_change_password": true,
+			})
+			c.Abort()
+			return
+		}
+
 		// 把 email 存入标准 context
 		ctx := context.WithValue(c.Request.Context(), ContextEmailKey, claims.Email)
 		c.Request = c.Request.WithContext(ctx)
+		c.Set(ContextUserKey, &u)
 
 		c.Next()
 		// 后续的处理函数可以通过c.Get("email")来获取当前请求的用户邮箱信息
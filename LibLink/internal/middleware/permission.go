@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"liblink/internal/global"
+	"liblink/internal/models/user"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const ContextUserKey = "currentUser"
+
+// CurrentUser 获取当前登录用户，同一请求内只查询一次数据库
+func CurrentUser(c *gin.Context) (*user.User, error) {
+	if v, ok := c.Get(ContextUserKey); ok {
+		return v.(*user.User), nil
+	}
+
+	var u user.User
+	if err := global.DB.Where("email = ?", GetEmail(c)).First(&u).Error; err != nil {
+		return nil, err
+	}
+	c.Set(ContextUserKey, &u)
+	return &u, nil
+}
+
+// RequirePermission 要求当前用户的角色拥有全部指定权限
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == "OPTIONS" {
+			c.Next()
+			return
+		}
+
+		u, err := CurrentUser(c)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "用户不存在"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "数据库错误"})
+			return
+		}
+
+		role, err := user.GetRole(global.DB, u.Role)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "用户角色无效"})
+			return
+		}
+
+		for _, p := range perms {
+			if !role.Has(p) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "权限不足", "permission": p})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
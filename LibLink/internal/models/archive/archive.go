@@ -2,42 +2,66 @@ package archive
 
 import (
 	"errors"
-	"strconv"
+	"fmt"
+	"liblink/pkg/fieldcrypt"
 	"strings"
-	"time"
 
 	"gorm.io/gorm"
 )
 
 type Archive struct {
 	gorm.Model
-	FileNo          string `gorm:"column:file_no;comment:'档案编号'" json:"file_no"`
-	ContractNo      string `gorm:"column:contract_no;comment:'合同编号'" json:"contract_no"`
-	Title           string `gorm:"column:title;comment:'档案标题'" json:"title"`
-	Name            string `gorm:"column:name;comment:'姓名'" json:"name"`
-	IDCard          string `gorm:"column:id_card;comment:'身份证号'" json:"id_card"`
-	InstNo          string `gorm:"column:inst_no;comment:'网点编号'" json:"inst_no"`
-	Manager         string `gorm:"column:manager;comment:'管户客户经理'" json:"manager"`
-	Amount          string `gorm:"column:amount;comment:'合同金额'" json:"amount"`
-	ArcType         string `gorm:"column:arc_type;comment:'文献类型'" json:"arc_type"`
-	BorrowState     string `gorm:"column:borrow_state;comment:'借阅状态'" json:"borrow_state"`
-	FolderID        uint   `gorm:"column:folder_id;comment:'文件夹ID'" json:"folder_id"`
-	CreatorID       string `gorm:"column:creator_id;comment:'创建者ID'" json:"creator_id"`
-	StorageDate     string `gorm:"column:storage_date;comment:'入库日期'" json:"storage_date"`
-	GroupPermission string `gorm:"column:group_permission;comment:'用户组权限,自动继承父文件夹权限,需要有其中所有权限才能够访问该档案'" json:"group_permission"`
+	FileNo          string            `gorm:"column:file_no;size:64;uniqueIndex:idx_archives_type_file_no,priority:2;comment:'档案编号'" json:"file_no"`
+	ContractNo      string            `gorm:"column:contract_no;size:128;uniqueIndex:idx_archives_contract_no;default:null;comment:'合同编号,为空时存 NULL'" json:"contract_no"`
+	Title           string            `gorm:"column:title;comment:'档案标题'" json:"title"`
+	Name            fieldcrypt.String `gorm:"column:name;comment:'姓名,加密存储'" json:"name"`
+	IDCard          fieldcrypt.String `gorm:"column:id_card;comment:'身份证号,加密存储'" json:"id_card"`
+	IDCardIndex     string            `gorm:"column:id_card_index;size:64;index;comment:'身份证号盲索引'" json:"-"`
+	InstNo          string            `gorm:"column:inst_no;comment:'网点编号'" json:"inst_no"`
+	Manager         string            `gorm:"column:manager;comment:'管户客户经理'" json:"manager"`
+	Amount          Money             `gorm:"column:amount;comment:'合同金额'" json:"amount"`
+	ArcType         string            `gorm:"column:arc_type;size:64;uniqueIndex:idx_archives_type_file_no,priority:1;comment:'文献类型'" json:"arc_type"`
+	BorrowState     string            `gorm:"column:borrow_state;comment:'借阅状态'" json:"borrow_state"`
+	FolderID        uint              `gorm:"column:folder_id;comment:'文件夹ID'" json:"folder_id"`
+	LocationID      uint              `gorm:"column:location_id;index;comment:'存放位置(档案盒)ID,未上架为 0'" json:"location_id"`
+	CreatorID       string            `gorm:"column:creator_id;comment:'创建者ID'" json:"creator_id"`
+	StorageDate     Date              `gorm:"column:storage_date;index;comment:'入库日期'" json:"storage_date"`
+	GroupPermission string            `gorm:"column:group_permission;comment:'用户组权限,自动继承父文件夹权限,需要有其中所有权限才能够访问该档案'" json:"group_permission"`
+	Extra           Metadata          `gorm:"column:extra;comment:'档案类型定义的自定义字段'" json:"extra"`
 }
 
 type ArchiveOperateUserKey string
 
 const ArchiveOperateUserID ArchiveOperateUserKey = "UserID"
 
+// BeforeCreate 写入身份证号盲索引
+func (a *Archive) BeforeCreate(tx *gorm.DB) (err error) {
+	a.IDCardIndex = IDCardIndex(string(a.IDCard))
+	return nil
+}
+
+// IDCardIndex 身份证号的盲索引，用于加密后按身份证号精确查询
+func IDCardIndex(idCard string) string {
+	return fieldcrypt.BlindIndex(strings.ToUpper(strings.TrimSpace(idCard)))
+}
+
+// ByIDCard 按身份证号精确查询，启用加密时使用盲索引
+func ByIDCard(idCard string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if fieldcrypt.Default() == nil {
+			return db.Where("id_card = ?", strings.TrimSpace(idCard))
+		}
+		return db.Where("id_card_index = ?", IDCardIndex(idCard))
+	}
+}
+
 // BeforeUpdate 更新了文献前，需要记录对应日志
 func (a *Archive) BeforeUpdate(tx *gorm.DB) (err error) {
 	changes := make(map[string]interface{})
 
 	// 检查借阅状态，日后如果需要添加其他的变更，则加入对应的监听
 	var old Archive
-	tx.Model(&Archive{}).Select("borrow_state").Where("contract_no = ?", a.ContractNo).Take(&old)
+	tx.Model(&Archive{}).Select("borrow_state").Where("id = ?", a.ID).Take(&old)
 	if old.BorrowState != a.BorrowState {
 		changes["borrow_state"] = map[string]interface{}{
 			"old": old.BorrowState,
@@ -54,7 +78,10 @@ func (a *Archive) BeforeUpdate(tx *gorm.DB) (err error) {
 				ContractNo:  a.ContractNo,
 				CreatorID:   operatorID,
 				OperateType: a.BorrowState, // 直接使用新的借阅状态作为操作类型
-				OperateDate: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+				OperateDate: Now(),
+			}
+			if loanID, ok := tx.Statement.Context.Value(ArchiveLoanID).(uint); ok {
+				log.LoanID = loanID
 			}
 
 			if err := tx.Save(&log).Error; err != nil {
@@ -178,54 +205,80 @@ func CreateFolder(DB *gorm.DB, name, path string, parentID uint, creatorID strin
 	return newFolder, nil
 }
 
-// CreateArchive 新增文献，自动继承所属文件夹的权限
-func CreateArchive(DB *gorm.DB, fileNo, title, contractNo, instNo, arcType, borrowState, creatorID string, folderID uint) (*Archive, error) {
-	if folderID == 0 {
-		return nil, errors.New("文献必须归属在一个文件夹下")
-	}
-
-	// 查找文件夹
-	var folder Folder
-	if err := DB.First(&folder, folderID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("所属文件夹不存在")
+// InBranches 将查询限定在指定网点内，all 为 true 时不做限制
+func InBranches(instNos []string, all bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if all {
+			return db
 		}
-		return nil, err
+		if len(instNos) == 0 {
+			return db.Where("1 = 0")
+		}
+		return db.Where("inst_no IN ?", instNos)
 	}
+}
 
-	// 创建文献，继承文件夹权限
-	newArchive := &Archive{
-		FileNo:          fileNo,
-		Title:           title,
-		ContractNo:      contractNo,
-		InstNo:          instNo,
-		ArcType:         arcType,
-		BorrowState:     borrowState,
-		FolderID:        folder.ID,
-		CreatorID:       creatorID,
-		GroupPermission: folder.GroupPermission, // 继承
+// EncryptPII 加密历史明文数据，或将旧密钥加密的数据用主密钥重新加密，同时补全盲索引
+// 按主键分批处理，可重复执行，返回更新的行数
+func EncryptPII(DB *gorm.DB, batchSize int) (int, error) {
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return 0, fieldcrypt.ErrNoKeyring
 	}
 
-	// 保存
-	if err := DB.Create(newArchive).Error; err != nil {
-		return nil, err
+	type piiRow struct {
+		ID          uint
+		Name        string
+		IDCard      string
+		IDCardIndex string
 	}
 
-	return newArchive, nil
-}
+	var updated int
+	var lastID uint
+	for {
+		// 以原始字符串读取，避免自动解密
+		var rows []piiRow
+		if err := DB.Table("archives").Select("id, name, id_card, id_card_index").
+			Where("id > ?", lastID).Order("id").Limit(batchSize).Scan(&rows).Error; err != nil {
+			return updated, err
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
 
-func GetArcTypeFileNo(DB *gorm.DB, arcType string) string {
-	var count int64
-	if err := DB.Model(&Archive{}).Where("arc_type = ?", arcType).Count(&count).Error; err != nil {
-		return ""
+		for _, row := range rows {
+			lastID = row.ID
+			name, err := keyring.Decrypt(row.Name)
+			if err != nil {
+				return updated, fmt.Errorf("archive %d: %w", row.ID, err)
+			}
+			idCard, err := keyring.Decrypt(row.IDCard)
+			if err != nil {
+				return updated, fmt.Errorf("archive %d: %w", row.ID, err)
+			}
+			index := IDCardIndex(idCard)
+			if keyring.IsCurrent(row.Name) && keyring.IsCurrent(row.IDCard) && row.IDCardIndex == index {
+				continue
+			}
+
+			updates := map[string]interface{}{
+				"name":          fieldcrypt.String(name),
+				"id_card":       fieldcrypt.String(idCard),
+				"id_card_index": index,
+			}
+			if err := DB.Table("archives").Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+				return updated, err
+			}
+			updated++
+		}
 	}
-	return strconv.Itoa(int(count + 1))
 }
 
 type ArchiveRecord struct {
 	gorm.Model
-	ContractNo  string `gorm:"column:contract_no;comment:'合同编号'" json:"contract_no"`
-	CreatorID   string `gorm:"column:creator_id;comment:'借阅人ID'" json:"creator_id"`
-	OperateType string `gorm:"column:operate_type;comment:'操作类型，借阅或归还'" json:"operate_type"`
-	OperateDate string `gorm:"column:operate_date;comment:'操作日期'" json:"operate_date"`
+	ContractNo  string   `gorm:"column:contract_no;comment:'合同编号'" json:"contract_no"`
+	CreatorID   string   `gorm:"column:creator_id;comment:'借阅人ID'" json:"creator_id"`
+	OperateType string   `gorm:"column:operate_type;comment:'操作类型，借阅或归还'" json:"operate_type"`
+	OperateDate DateTime `gorm:"column:operate_date;comment:'操作日期'" json:"operate_date"`
+	LoanID      uint     `gorm:"column:loan_id;index;comment:'柜台借还批次ID,单份操作为 0'" json:"loan_id"`
 }
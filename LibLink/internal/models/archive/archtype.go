@@ -0,0 +1,401 @@
+package archive
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// 自定义字段类型
+const (
+	FieldText   = "text"   // 文本
+	FieldNumber = "number" // 数值
+	FieldDate   = "date"   // 日期，存储为 2006-01-02
+	FieldSelect = "select" // 单选，取值限定在 Options 中
+	FieldBool   = "bool"   // 是/否
+)
+
+// ArchiveType 档案类型，定义编号规则、保管期限与该类型特有的字段
+type ArchiveType struct {
+	gorm.Model
+	Code           string    `gorm:"column:code;size:64;uniqueIndex;comment:'类型代码,对应档案的 arc_type'" json:"code"`
+	Name           string    `gorm:"column:name;comment:'类型名称'" json:"name"`
+	RetentionYears int       `gorm:"column:retention_years;comment:'保管期限(年),0 为永久'" json:"retention_years"`
+	FileNoTemplate string    `gorm:"column:file_no_template;size:128;comment:'档案编号模板,为空时使用系统默认模板'" json:"file_no_template"`
+	Fields         FieldDefs `gorm:"column:fields;comment:'自定义字段定义'" json:"fields"`
+	Disabled       bool      `gorm:"column:disabled;comment:'停用后不能新增该类型的档案'" json:"disabled"`
+}
+
+// Check 校验类型定义
+func (t *ArchiveType) Check() error {
+	if strings.TrimSpace(t.Code) == "" || strings.TrimSpace(t.Name) == "" {
+		return errors.New("类型代码与名称不能为空")
+	}
+	if len(t.Code) > 64 {
+		return errors.New("类型代码不能超过 64 个字符")
+	}
+	if t.RetentionYears < 0 {
+		return errors.New("保管期限不能为负数")
+	}
+	if t.FileNoTemplate != "" {
+		if err := ValidateFileNoTemplate(t.FileNoTemplate); err != nil {
+			return err
+		}
+	}
+	return t.Fields.Check()
+}
+
+// FieldDef 自定义字段定义
+type FieldDef struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// FieldDefs 自定义字段定义列表，以 JSON 存储
+type FieldDefs []FieldDef
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// Check 校验字段定义：键名唯一且只含小写字母、数字与下划线，类型受支持，单选字段必须提供选项
+func (defs FieldDefs) Check() error {
+	seen := make(map[string]struct{})
+	for _, d := range defs {
+		if !fieldKeyPattern.MatchString(d.Key) {
+			return fmt.Errorf("字段键名 %q 无效，只能包含小写字母、数字与下划线且以字母开头", d.Key)
+		}
+		if _, ok := seen[d.Key]; ok {
+			return fmt.Errorf("字段键名 %q 重复", d.Key)
+		}
+		seen[d.Key] = struct{}{}
+		if strings.TrimSpace(d.Label) == "" {
+			return fmt.Errorf("字段 %s 缺少名称", d.Key)
+		}
+		switch d.Type {
+		case FieldText, FieldNumber, FieldDate, FieldBool:
+		case FieldSelect:
+			if len(d.Options) == 0 {
+				return fmt.Errorf("单选字段 %s 缺少选项", d.Key)
+			}
+		default:
+			return fmt.Errorf("字段 %s 的类型 %q 不受支持", d.Key, d.Type)
+		}
+	}
+	return nil
+}
+
+// Field 按键名或名称查找字段定义
+func (defs FieldDefs) Field(name string) (FieldDef, bool) {
+	name = strings.TrimSpace(name)
+	for _, d := range defs {
+		if d.Key == name || d.Label == name {
+			return d, true
+		}
+	}
+	return FieldDef{}, false
+}
+
+func (defs FieldDefs) Value() (driver.Value, error) {
+	if defs == nil {
+		defs = FieldDefs{}
+	}
+	b, err := json.Marshal(defs)
+	return string(b), err
+}
+
+func (defs *FieldDefs) Scan(value interface{}) error {
+	return scanJSON(value, defs)
+}
+
+func (FieldDefs) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "JSON"
+}
+
+// Metadata 档案自定义字段的取值，以 JSON 存储
+type Metadata map[string]interface{}
+
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *Metadata) Scan(value interface{}) error {
+	return scanJSON(value, m)
+}
+
+func (Metadata) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "JSON"
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported json type %T", value)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// GetArchiveType 按代码获取档案类型
+func GetArchiveType(DB *gorm.DB, code string) (*ArchiveType, error) {
+	var t ArchiveType
+	if err := DB.Where("code = ?", code).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SeedArchiveTypes 为已有档案中出现过的类型补充登记，名称默认与代码相同
+func SeedArchiveTypes(DB *gorm.DB) error {
+	var codes []string
+	if err := DB.Model(&Archive{}).Unscoped().Distinct("arc_type").
+		Where("arc_type <> ''").Pluck("arc_type", &codes).Error; err != nil {
+		return err
+	}
+	for _, code := range codes {
+		t := ArchiveType{Code: code, Name: code}
+		if err := DB.Where(ArchiveType{Code: code}).FirstOrCreate(&t).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadArchiveTypes 按代码批量加载档案类型
+func LoadArchiveTypes(DB *gorm.DB, codes ...string) (map[string]*ArchiveType, error) {
+	types := make(map[string]*ArchiveType)
+	if len(codes) == 0 {
+		return types, nil
+	}
+	var list []ArchiveType
+	if err := DB.Where("code IN ?", codes).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		types[list[i].Code] = &list[i]
+	}
+	return types, nil
+}
+
+// ArcType 校验档案类型已登记且未停用，返回对应的类型定义，types 由 LoadArchiveTypes 加载
+func (v *Validator) ArcType(types map[string]*ArchiveType, code string) *ArchiveType {
+	code = v.Required("arc_type", code)
+	if code == "" {
+		return nil
+	}
+	t, ok := types[code]
+	if !ok || t.Disabled {
+		v.fail("arc_type", ErrCodeArcTypeUnknown, code)
+		return nil
+	}
+	return t
+}
+
+// Extra 按档案类型的字段定义校验并规范化自定义字段，未定义的字段报错
+func (v *Validator) Extra(t *ArchiveType, extra Metadata) Metadata {
+	result := Metadata{}
+	for key := range extra {
+		if _, ok := t.Fields.byKey(key); !ok {
+			v.Errors = append(v.Errors, ValidationError{Row: v.Row, Field: "extra." + key, Label: key, Code: ErrCodeFieldUnknown})
+		}
+	}
+	for _, d := range t.Fields {
+		raw, ok := extra[d.Key]
+		value, code := d.Normalize(raw)
+		if code == "" && value == nil && d.Required {
+			code = ErrCodeRequired
+		}
+		if code != "" {
+			v.Errors = append(v.Errors, ValidationError{Row: v.Row, Field: "extra." + d.Key, Label: d.Label, Code: code, Value: fmt.Sprint(raw)})
+			continue
+		}
+		if ok && value != nil {
+			result[d.Key] = value
+		}
+	}
+	return result
+}
+
+func (defs FieldDefs) byKey(key string) (FieldDef, bool) {
+	for _, d := range defs {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return FieldDef{}, false
+}
+
+// Normalize 将输入转换为字段类型对应的存储值，空值返回 nil，失败时返回错误码
+// 文本、单选与日期存为字符串，数值存为数字，是/否存为布尔值
+func (d FieldDef) Normalize(raw interface{}) (interface{}, string) {
+	if raw == nil {
+		return nil, ""
+	}
+	var s string
+	switch v := raw.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		s = v.String()
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return nil, ErrCodeFieldType
+	}
+	if s == "" {
+		return nil, ""
+	}
+
+	switch d.Type {
+	case FieldText:
+		if _, ok := raw.(string); !ok {
+			return nil, ErrCodeFieldType
+		}
+		return s, ""
+	case FieldNumber:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+		if err != nil {
+			return nil, ErrCodeFieldType
+		}
+		return n, ""
+	case FieldDate:
+		t, err := ParseDate(s)
+		if err != nil {
+			return nil, ErrCodeDateFormat
+		}
+		return t.Format(DateLayout), ""
+	case FieldSelect:
+		for _, o := range d.Options {
+			if o == s {
+				return s, ""
+			}
+		}
+		return nil, ErrCodeFieldOption
+	case FieldBool:
+		switch strings.ToLower(s) {
+		case "true", "1", "是", "yes", "y":
+			return true, ""
+		case "false", "0", "否", "no", "n":
+			return false, ""
+		}
+		return nil, ErrCodeFieldType
+	}
+	return nil, ErrCodeFieldType
+}
+
+// ExtraFilter 自定义字段的查询条件，eq 为精确匹配（文本为模糊匹配），min/max 为数值或日期的范围
+type ExtraFilter struct {
+	Eq  map[string]string `json:"eq,omitempty"`
+	Min map[string]string `json:"min,omitempty"`
+	Max map[string]string `json:"max,omitempty"`
+}
+
+// Empty 是否没有任何自定义字段条件
+func (f ExtraFilter) Empty() bool {
+	return len(f.Eq) == 0 && len(f.Min) == 0 && len(f.Max) == 0
+}
+
+// ExtraScope 按档案类型的字段定义生成自定义字段的查询条件，字段不存在或取值无效时记录到校验错误
+func (v *Validator) ExtraScope(t *ArchiveType, f ExtraFilter) func(db *gorm.DB) *gorm.DB {
+	type condition struct {
+		sql  string
+		args []interface{}
+	}
+	var conds []condition
+
+	lookup := func(key string) (FieldDef, bool) {
+		d, ok := t.Fields.byKey(key)
+		if !ok {
+			v.Errors = append(v.Errors, ValidationError{Field: "extra." + key, Label: key, Code: ErrCodeFieldUnknown})
+			return d, false
+		}
+		return d, true
+	}
+	normalize := func(d FieldDef, raw string) (interface{}, bool) {
+		value, code := d.Normalize(raw)
+		if code != "" {
+			v.Errors = append(v.Errors, ValidationError{Field: "extra." + d.Key, Label: d.Label, Code: code, Value: raw})
+			return nil, false
+		}
+		return value, value != nil
+	}
+
+	for key, raw := range f.Eq {
+		d, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if d.Type == FieldText {
+			conds = append(conds, condition{"JSON_UNQUOTE(JSON_EXTRACT(extra, ?)) LIKE ?", []interface{}{jsonPath(key), "%" + strings.TrimSpace(raw) + "%"}})
+			continue
+		}
+		value, ok := normalize(d, raw)
+		if !ok {
+			continue
+		}
+		if d.Type == FieldNumber {
+			conds = append(conds, condition{"CAST(JSON_EXTRACT(extra, ?) AS DECIMAL(30,6)) = ?", []interface{}{jsonPath(key), value}})
+			continue
+		}
+		conds = append(conds, condition{"JSON_UNQUOTE(JSON_EXTRACT(extra, ?)) = ?", []interface{}{jsonPath(key), fmt.Sprint(value)}})
+	}
+
+	rangeCond := func(bounds map[string]string, op string) {
+		for key, raw := range bounds {
+			d, ok := lookup(key)
+			if !ok {
+				continue
+			}
+			value, ok := normalize(d, raw)
+			if !ok {
+				continue
+			}
+			switch d.Type {
+			case FieldNumber:
+				conds = append(conds, condition{"CAST(JSON_EXTRACT(extra, ?) AS DECIMAL(30,6)) " + op + " ?", []interface{}{jsonPath(key), value}})
+			case FieldDate:
+				conds = append(conds, condition{"JSON_UNQUOTE(JSON_EXTRACT(extra, ?)) " + op + " ?", []interface{}{jsonPath(key), value}})
+			default:
+				v.Errors = append(v.Errors, ValidationError{Field: "extra." + key, Label: d.Label, Code: ErrCodeFieldRange})
+			}
+		}
+	}
+	rangeCond(f.Min, ">=")
+	rangeCond(f.Max, "<=")
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, c := range conds {
+			db = db.Where(c.sql, c.args...)
+		}
+		return db
+	}
+}
+
+// jsonPath 字段键名已限定为小写字母、数字与下划线，可直接拼接为 JSON 路径
+func jsonPath(key string) string {
+	return "$." + key
+}
@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldDefsCheck(t *testing.T) {
+	valid := FieldDefs{
+		{Key: "dept", Label: "部门", Type: FieldText},
+		{Key: "level", Label: "密级", Type: FieldSelect, Options: []string{"公开", "内部"}},
+	}
+	if err := valid.Check(); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+
+	invalid := map[string]FieldDefs{
+		"bad key":        {{Key: "Dept", Label: "部门", Type: FieldText}},
+		"duplicate key":  {{Key: "dept", Label: "部门", Type: FieldText}, {Key: "dept", Label: "科室", Type: FieldText}},
+		"missing label":  {{Key: "dept", Type: FieldText}},
+		"unknown type":   {{Key: "dept", Label: "部门", Type: "json"}},
+		"select no opts": {{Key: "level", Label: "密级", Type: FieldSelect}},
+	}
+	for name, defs := range invalid {
+		if err := defs.Check(); err == nil {
+			t.Errorf("%s: Check() should fail", name)
+		}
+	}
+}
+
+func TestArchiveTypeCheck(t *testing.T) {
+	at := ArchiveType{Code: "HR", Name: "人事档案", FileNoTemplate: "{type}-{seq}"}
+	if err := at.Check(); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	at.FileNoTemplate = "{type}"
+	if err := at.Check(); err == nil {
+		t.Error("template without {seq} should fail")
+	}
+	at.FileNoTemplate, at.RetentionYears = "", -1
+	if err := at.Check(); err == nil {
+		t.Error("negative retention should fail")
+	}
+}
+
+func TestValidatorExtra(t *testing.T) {
+	at := &ArchiveType{Code: "HR", Fields: FieldDefs{
+		{Key: "dept", Label: "部门", Type: FieldText, Required: true},
+		{Key: "salary", Label: "薪资", Type: FieldNumber},
+		{Key: "hired", Label: "入职日期", Type: FieldDate},
+		{Key: "level", Label: "密级", Type: FieldSelect, Options: []string{"公开", "内部"}},
+		{Key: "active", Label: "在职", Type: FieldBool},
+	}}
+
+	v := &Validator{}
+	got := v.Extra(at, Metadata{"dept": " 财务部 ", "salary": "8,000.5", "hired": "2020/1/2", "level": "内部", "active": "是"})
+	if !v.Valid() {
+		t.Fatalf("Extra() errors = %v", v.Errors)
+	}
+	want := Metadata{"dept": "财务部", "salary": 8000.5, "hired": "2020-01-02", "level": "内部", "active": true}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("extra[%s] = %#v, want %#v", k, got[k], w)
+		}
+	}
+
+	v = &Validator{Row: 3}
+	v.Extra(at, Metadata{"salary": "abc", "level": "机密", "unknown": "x"})
+	codes := make(map[string]string)
+	for _, e := range v.Errors {
+		codes[e.Field] = e.Code
+	}
+	expected := map[string]string{
+		"extra.dept":    ErrCodeRequired,
+		"extra.salary":  ErrCodeFieldType,
+		"extra.level":   ErrCodeFieldOption,
+		"extra.unknown": ErrCodeFieldUnknown,
+	}
+	for field, code := range expected {
+		if codes[field] != code {
+			t.Errorf("%s: code = %q, want %q", field, codes[field], code)
+		}
+	}
+	if msg := v.Errors[0].Message("zh"); !strings.HasPrefix(msg, "第3行") {
+		t.Errorf("Message() = %q", msg)
+	}
+}
+
+func TestValidatorArcType(t *testing.T) {
+	types := map[string]*ArchiveType{
+		"HR":  {Code: "HR"},
+		"OLD": {Code: "OLD", Disabled: true},
+	}
+	v := &Validator{}
+	if v.ArcType(types, "HR") == nil || !v.Valid() {
+		t.Fatalf("registered type rejected: %v", v.Errors)
+	}
+	for _, code := range []string{"", "OLD", "NONE"} {
+		v := &Validator{}
+		if v.ArcType(types, code) != nil || v.Valid() {
+			t.Errorf("ArcType(%q) should fail", code)
+		}
+	}
+}
+
+func TestMessageUsesFieldLabel(t *testing.T) {
+	e := ValidationError{Field: "extra.dept", Label: "部门", Code: ErrCodeRequired}
+	if got := e.Message("zh"); got != "部门不能为空" {
+		t.Errorf("Message() = %q", got)
+	}
+}
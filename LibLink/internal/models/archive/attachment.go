@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"liblink/pkg/storage"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 支持的附件格式
+const (
+	ContentTypePDF  = "application/pdf"
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypeTIFF = "image/tiff"
+)
+
+// Attachment 档案的电子扫描件，文件内容保存在对象存储中
+// 每次重新上传生成一个新版本，以下文件字段为当前版本的副本
+type Attachment struct {
+	gorm.Model
+	ArchiveID   uint   `gorm:"column:archive_id;index;comment:'档案ID'" json:"archive_id"`
+	Version     int    `gorm:"column:version;comment:'当前版本号'" json:"version"`
+	FileName    string `gorm:"column:file_name;comment:'原始文件名'" json:"file_name"`
+	ContentType string `gorm:"column:content_type;size:64;comment:'文件类型'" json:"content_type"`
+	Size        int64  `gorm:"column:size;comment:'文件大小(字节)'" json:"size"`
+	SHA256      string `gorm:"column:sha256;size:64;comment:'文件 SHA-256 校验和'" json:"sha256"`
+	StorageKey  string `gorm:"column:storage_key;size:255;comment:'对象存储中的键'" json:"-"`
+	UploaderID  string `gorm:"column:uploader_id;comment:'上传者'" json:"uploader_id"`
+}
+
+// AttachmentVersion 附件的历史版本，文件相关字段写入后不再修改
+// VerifiedAt 与 Corrupted 记录最近一次完整性校验的结果
+type AttachmentVersion struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	AttachmentID uint      `gorm:"column:attachment_id;uniqueIndex:idx_attachment_version,priority:1;comment:'附件ID'" json:"attachment_id"`
+	Version      int       `gorm:"column:version;uniqueIndex:idx_attachment_version,priority:2;comment:'版本号,从 1 开始'" json:"version"`
+	FileName     string    `gorm:"column:file_name;comment:'原始文件名'" json:"file_name"`
+	ContentType  string    `gorm:"column:content_type;size:64;comment:'文件类型'" json:"content_type"`
+	Size         int64     `gorm:"column:size;comment:'文件大小(字节)'" json:"size"`
+	SHA256       string    `gorm:"column:sha256;size:64;comment:'文件 SHA-256 校验和'" json:"sha256"`
+	StorageKey   string    `gorm:"column:storage_key;size:255;comment:'对象存储中的键'" json:"-"`
+	UploaderID   string    `gorm:"column:uploader_id;comment:'上传者'" json:"uploader_id"`
+	VerifiedAt   DateTime  `gorm:"column:verified_at;comment:'最近一次完整性校验时间'" json:"verified_at"`
+	Corrupted    bool      `gorm:"column:corrupted;comment:'文件缺失或校验和不一致'" json:"corrupted"`
+}
+
+// SaveAttachmentVersion 保存新版本并设为附件的当前版本，att.ID 为 0 时新建附件
+// 版本号在事务中锁定附件后递增，并发上传不会重复，新版本同时加入 OCR 识别队列
+func SaveAttachmentVersion(DB *gorm.DB, att *Attachment, v *AttachmentVersion) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if att.ID == 0 {
+			if err := tx.Create(att).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(att, att.ID).Error; err != nil {
+			return err
+		}
+
+		v.AttachmentID = att.ID
+		v.Version = att.Version + 1
+		if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+
+		att.Version = v.Version
+		att.FileName = v.FileName
+		att.ContentType = v.ContentType
+		att.Size = v.Size
+		att.SHA256 = v.SHA256
+		att.StorageKey = v.StorageKey
+		att.UploaderID = v.UploaderID
+		if err := tx.Save(att).Error; err != nil {
+			return err
+		}
+		return queueOCR(tx, att)
+	})
+}
+
+// DetectContentType 根据文件头识别附件格式，不支持的格式返回 false
+// 不依赖客户端提供的 Content-Type 与扩展名
+func DetectContentType(head []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return ContentTypePDF, true
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return ContentTypeJPEG, true
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return ContentTypeTIFF, true
+	}
+	return "", false
+}
+
+// NewStorageKey 生成附件的存储键，不使用原始文件名以避免路径与编码问题
+func NewStorageKey(archiveID uint) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("archives/%d/%s", archiveID, hex.EncodeToString(b))
+}
+
+// 完整性校验失败原因
+const (
+	IntegrityMissing  = "missing"  // 存储中找不到文件
+	IntegrityMismatch = "mismatch" // 校验和不一致
+)
+
+// IntegrityFailure 完整性校验失败的附件版本
+type IntegrityFailure struct {
+	Version AttachmentVersion
+	Reason  string
+	Actual  string // 实际的校验和，文件缺失时为空
+}
+
+// VerifyAttachments 重新计算存储中全部附件版本的校验和并记录结果
+// 返回本次新发现的失败版本（此前已标记为损坏的不重复返回）与校验的版本数
+func VerifyAttachments(ctx context.Context, DB *gorm.DB, store storage.Storage, batchSize int) ([]IntegrityFailure, int, error) {
+	var failures []IntegrityFailure
+	var checked int
+	var lastID uint
+	for {
+		var versions []AttachmentVersion
+		if err := DB.Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&versions).Error; err != nil {
+			return failures, checked, err
+		}
+		if len(versions) == 0 {
+			return failures, checked, nil
+		}
+
+		for _, v := range versions {
+			lastID = v.ID
+			reason, actual, err := verifyBlob(ctx, store, v)
+			if err != nil {
+				return failures, checked, fmt.Errorf("verify attachment version %d: %w", v.ID, err)
+			}
+			checked++
+
+			corrupted := reason != ""
+			if corrupted && !v.Corrupted {
+				failures = append(failures, IntegrityFailure{Version: v, Reason: reason, Actual: actual})
+			}
+			if err := DB.Model(&v).UpdateColumns(map[string]interface{}{
+				"verified_at": Now(),
+				"corrupted":   corrupted,
+			}).Error; err != nil {
+				return failures, checked, err
+			}
+		}
+	}
+}
+
+// verifyBlob 读取文件并计算校验和，存储不可用等错误直接返回，不视为文件损坏
+func verifyBlob(ctx context.Context, store storage.Storage, v AttachmentVersion) (reason, actual string, err error) {
+	r, err := store.Get(ctx, v.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return IntegrityMissing, "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", "", err
+	}
+	actual = hex.EncodeToString(hash.Sum(nil))
+	if actual != v.SHA256 {
+		return IntegrityMismatch, actual, nil
+	}
+	return "", actual, nil
+}
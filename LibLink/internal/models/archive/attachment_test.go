@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"context"
+	"liblink/pkg/storage"
+	"strings"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		head string
+		want string
+		ok   bool
+	}{
+		{"%PDF-1.7\n", ContentTypePDF, true},
+		{"\xFF\xD8\xFF\xE0\x00\x10JFIF", ContentTypeJPEG, true},
+		{"II*\x00\x08\x00", ContentTypeTIFF, true},
+		{"MM\x00*\x00\x00", ContentTypeTIFF, true},
+		{"\x89PNG\r\n\x1a\n", "", false},
+		{"<html>", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := DetectContentType([]byte(c.head))
+		if got != c.want || ok != c.ok {
+			t.Errorf("DetectContentType(%q) = %q, %v", c.head, got, ok)
+		}
+	}
+}
+
+func TestNewStorageKey(t *testing.T) {
+	a, b := NewStorageKey(12), NewStorageKey(12)
+	if !strings.HasPrefix(a, "archives/12/") || len(a) != len("archives/12/")+32 {
+		t.Errorf("NewStorageKey() = %q", a)
+	}
+	if a == b {
+		t.Error("NewStorageKey() should be random")
+	}
+}
+
+func TestVerifyBlob(t *testing.T) {
+	ctx := context.Background()
+	store := &storage.Local{Root: t.TempDir()}
+	data := "%PDF-1.4 scan"
+	if err := store.Put(ctx, "archives/1/a", strings.NewReader(data), int64(len(data)), ContentTypePDF); err != nil {
+		t.Fatal(err)
+	}
+	const sum = "5e5e5f2d2c0b0ff2a40fb70bfa1e5c9b8bd8d4c5fbe0d9d6bfbd5e5c40db9e1c"
+
+	v := AttachmentVersion{StorageKey: "archives/1/a"}
+	_, actual, err := verifyBlob(ctx, store, v)
+	if err != nil || len(actual) != 64 {
+		t.Fatalf("verifyBlob() = %q, %v", actual, err)
+	}
+
+	v.SHA256 = actual
+	if reason, _, err := verifyBlob(ctx, store, v); reason != "" || err != nil {
+		t.Errorf("verifyBlob() intact = %q, %v", reason, err)
+	}
+	v.SHA256 = sum
+	if reason, got, _ := verifyBlob(ctx, store, v); reason != IntegrityMismatch || got != actual {
+		t.Errorf("verifyBlob() mismatch = %q, %q", reason, got)
+	}
+	v.StorageKey = "archives/1/missing"
+	if reason, _, err := verifyBlob(ctx, store, v); reason != IntegrityMissing || err != nil {
+		t.Errorf("verifyBlob() missing = %q, %v", reason, err)
+	}
+}
+
+func TestFulltextQuery(t *testing.T) {
+	cases := map[string]string{
+		"借款合同":                `+"借款合同"`,
+		"  张三   借款 ":          `+"张三" +"借款"`,
+		`"a" +b -c (d)* e~@f`: `+"a" +"b" +"c" +"d" +"e" +"f"`,
+		`"+-`:                 "",
+	}
+	for q, want := range cases {
+		if got := FulltextQuery(q); got != want {
+			t.Errorf("FulltextQuery(%q) = %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	content := "甲方：张三\n乙方：某某银行\n借款金额：人民币 100,000 元，借款期限 12 个月。<script>"
+	got := Highlight(content, []string{"借款"}, 4, 3)
+	want := []string{"…某银行 <em>借款</em>金额：人…", "…0 元，<em>借款</em>期限 1…"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+
+	// 相邻的命中合并为一个片段
+	got = Highlight(content, []string{"借款"}, 18, 3)
+	want = []string{"甲方：张三 乙方：某某银行 <em>借款</em>金额：人民币 100,000 元，<em>借款</em>期限 12 个月。&lt;script&gt;"}
+	if len(got) != len(want) || got[0] != want[0] {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+
+	got = Highlight(content, []string{"张三", "SCRIPT"}, 2, 3)
+	want = []string{"…方：<em>张三</em> 乙…", "…。&lt;<em>script</em>&gt;"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+
+	if got := Highlight(content, []string{"张三", "借款"}, 2, 1); len(got) != 1 {
+		t.Errorf("Highlight() limit = %q", got)
+	}
+	if got := Highlight(content, []string{"不存在"}, 2, 3); got != nil {
+		t.Errorf("Highlight() no hit = %q", got)
+	}
+}
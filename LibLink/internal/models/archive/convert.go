@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// LegacyValue 迁移时无法转换或被修改的历史数据，原值保留在此表中以便人工核对
+type LegacyValue struct {
+	gorm.Model
+	Table  string `gorm:"column:table_name;size:64;comment:'来源表'" json:"table_name"`
+	RowID  uint   `gorm:"column:row_id;comment:'来源行ID'" json:"row_id"`
+	Column string `gorm:"column:column_name;size:64;comment:'来源字段'" json:"column_name"`
+	Value  string `gorm:"column:value;comment:'原始值'" json:"value"`
+}
+
+// typedColumn 由字符串转换为强类型的字段
+type typedColumn struct {
+	table   string
+	column  string
+	sqlType string
+	comment string
+	parse   func(string) (interface{}, bool)
+}
+
+var typedColumns = []typedColumn{
+	{"archives", "amount", "DECIMAL(18,2)", "合同金额", func(s string) (interface{}, bool) {
+		m, code := ParseMoney(s)
+		return m, code == ""
+	}},
+	{"archives", "storage_date", "DATE", "入库日期", func(s string) (interface{}, bool) {
+		return ParseDateValue(s)
+	}},
+	{"archive_records", "operate_date", "DATETIME(3)", "操作日期", func(s string) (interface{}, bool) {
+		t, err := ParseDateTime(s)
+		if err != nil {
+			return DateTime{}, false
+		}
+		return NewDateTime(t), true
+	}},
+}
+
+// ConvertTypedColumns 将金额、日期字段由字符串转换为 DECIMAL/DATE/DATETIME
+// 先写入临时列再替换原列，无法解析的值置空并记录到 legacy_values，返回这些值
+// 已转换的字段会被跳过，可重复执行
+// 每个字段的转换由多条 DDL 组成，MySQL 中 DDL 会隐式提交，整个过程不是原子的；
+// 中途失败后重新执行会从残留的临时列继续
+func ConvertTypedColumns(DB *gorm.DB) ([]LegacyValue, error) {
+	if err := DB.AutoMigrate(&LegacyValue{}); err != nil {
+		return nil, err
+	}
+
+	var legacy []LegacyValue
+	for _, col := range typedColumns {
+		values, err := convertColumn(DB, col)
+		if err != nil {
+			return legacy, fmt.Errorf("convert %s.%s: %w", col.table, col.column, err)
+		}
+		legacy = append(legacy, values...)
+	}
+	return legacy, nil
+}
+
+// convertColumn 依次执行 ADD 临时列、复制数据、DROP 原列、CHANGE 临时列为原列名，
+// 每一步都可重复执行：原列已删除而临时列仍在时直接从 CHANGE 继续
+func convertColumn(DB *gorm.DB, col typedColumn) ([]LegacyValue, error) {
+	m := DB.Migrator()
+	if !m.HasTable(col.table) {
+		return nil, nil
+	}
+	columnTypes, err := m.ColumnTypes(col.table)
+	if err != nil {
+		return nil, err
+	}
+	tmp := col.column + "_typed"
+	var current string
+	var hasTmp bool
+	for _, ct := range columnTypes {
+		switch ct.Name() {
+		case col.column:
+			current = strings.ToUpper(ct.DatabaseTypeName())
+		case tmp:
+			hasTmp = true
+		}
+	}
+	if current == "" {
+		if hasTmp {
+			// 上次在 DROP 与 CHANGE 之间中断
+			return nil, renameTypedColumn(DB, col, tmp)
+		}
+		return nil, nil
+	}
+	if !isTextType(current) {
+		return nil, nil
+	}
+
+	if !hasTmp {
+		if err := DB.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s NULL", col.table, tmp, col.sqlType)).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	type rawRow struct {
+		ID    uint
+		Value *string
+	}
+
+	var legacy []LegacyValue
+	var lastID uint
+	for {
+		var rows []rawRow
+		if err := DB.Table(col.table).Select(fmt.Sprintf("id, `%s` AS value", col.column)).
+			Where("id > ?", lastID).Order("id").Limit(500).Scan(&rows).Error; err != nil {
+			return legacy, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			if row.Value == nil || strings.TrimSpace(*row.Value) == "" {
+				continue
+			}
+			value, ok := col.parse(strings.TrimSpace(*row.Value))
+			if !ok {
+				lv := LegacyValue{Table: col.table, RowID: row.ID, Column: col.column, Value: *row.Value}
+				// 重新执行时不重复记录
+				if err := DB.Where(LegacyValue{Table: col.table, RowID: row.ID, Column: col.column}).
+					FirstOrCreate(&lv).Error; err != nil {
+					return legacy, err
+				}
+				legacy = append(legacy, lv)
+				continue
+			}
+			if err := DB.Table(col.table).Where("id = ?", row.ID).UpdateColumn(tmp, value).Error; err != nil {
+				return legacy, err
+			}
+		}
+	}
+
+	if err := DB.Exec(fmt.Sprintf("ALTER TABLE `%s` DROP COLUMN `%s`", col.table, col.column)).Error; err != nil {
+		return legacy, err
+	}
+	return legacy, renameTypedColumn(DB, col, tmp)
+}
+
+// renameTypedColumn 将临时列改名为原列名
+func renameTypedColumn(DB *gorm.DB, col typedColumn, tmp string) error {
+	return DB.Exec(fmt.Sprintf("ALTER TABLE `%s` CHANGE `%s` `%s` %s NULL COMMENT '%s'", col.table, tmp, col.column, col.sqlType, col.comment)).Error
+}
+
+func isTextType(t string) bool {
+	return strings.Contains(t, "CHAR") || strings.Contains(t, "TEXT")
+}
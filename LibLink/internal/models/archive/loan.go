@@ -0,0 +1,41 @@
+package archive
+
+import (
+	"gorm.io/gorm"
+)
+
+// ArchiveLoanID 上下文中的借还批次ID，借阅记录据此关联到同一批次
+const ArchiveLoanID ArchiveOperateUserKey = "LoanID"
+
+// 借还操作，取值与借阅状态一致
+const (
+	LoanBorrow = "1"
+	LoanReturn = "0"
+)
+
+// 借还批次状态，柜台直接办理的批次为已通过，借阅申请需审批后才会借出
+const (
+	LoanPending  = "pending"
+	LoanApproved = "approved"
+	LoanRejected = "rejected"
+)
+
+// Loan 借还批次，柜台扫码借还或借阅申请，一次提交的多份档案属于同一借阅人
+type Loan struct {
+	gorm.Model
+	Operation  string `gorm:"column:operation;size:8;comment:'1 借阅,0 归还'" json:"operation"`
+	Status     string `gorm:"column:status;size:16;index;comment:'审批状态';default:approved" json:"status"`
+	BorrowerID string `gorm:"column:borrower_id;index;comment:'借阅人'" json:"borrower_id"`
+	OperatorID string `gorm:"column:operator_id;comment:'经办人或申请人'" json:"operator_id"`
+	ApproverID string `gorm:"column:approver_id;comment:'审批人'" json:"approver_id"`
+	Remark     string `gorm:"column:remark;comment:'备注'" json:"remark"`
+	Total      int    `gorm:"column:total;comment:'扫描的编码数或申请的档案数'" json:"total"`
+	Succeeded  int    `gorm:"column:succeeded;comment:'成功的档案数'" json:"succeeded"`
+}
+
+// LoanItem 借阅申请包含的档案，审批通过时逐份借出
+type LoanItem struct {
+	ID        uint `gorm:"primarykey" json:"id"`
+	LoanID    uint `gorm:"column:loan_id;index;comment:'借还批次ID'" json:"loan_id"`
+	ArchiveID uint `gorm:"column:archive_id;index;comment:'档案ID'" json:"archive_id"`
+}
@@ -0,0 +1,40 @@
+package archive
+
+import (
+	"liblink/pkg/fieldcrypt"
+	"strings"
+)
+
+// MaskIDCard 身份证号脱敏，保留前 6 位与后 4 位
+func MaskIDCard(idCard string) string {
+	r := []rune(idCard)
+	if len(r) <= 10 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[:6]) + strings.Repeat("*", len(r)-10) + string(r[len(r)-4:])
+}
+
+// MaskName 姓名脱敏，仅保留第一个字
+func MaskName(name string) string {
+	r := []rune(name)
+	if len(r) == 0 {
+		return ""
+	}
+	return string(r[:1]) + strings.Repeat("*", len(r)-1)
+}
+
+// MaskAmount 合同金额脱敏
+func MaskAmount(amount string) string {
+	if amount == "" {
+		return ""
+	}
+	return "****"
+}
+
+// Masked 返回脱敏后的档案副本，用于无敏感信息查看权限的用户
+func (a Archive) Masked() Archive {
+	a.Name = fieldcrypt.String(MaskName(string(a.Name)))
+	a.IDCard = fieldcrypt.String(MaskIDCard(string(a.IDCard)))
+	a.Amount = Money{raw: MaskAmount(a.Amount.String())}
+	return a
+}
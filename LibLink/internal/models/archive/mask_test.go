@@ -0,0 +1,47 @@
+package archive
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMaskIDCard(t *testing.T) {
+	cases := map[string]string{
+		"110101199003074514": "110101********4514",
+		"11010119900307451X": "110101********451X",
+		"110101900307451":    "110101*****7451",
+		"1234":               "****",
+		"":                   "",
+	}
+	for in, want := range cases {
+		if got := MaskIDCard(in); got != want {
+			t.Errorf("MaskIDCard(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMaskName(t *testing.T) {
+	cases := map[string]string{
+		"张三":   "张*",
+		"欧阳娜娜": "欧***",
+		"李":    "李",
+		"":     "",
+	}
+	for in, want := range cases {
+		if got := MaskName(in); got != want {
+			t.Errorf("MaskName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMasked(t *testing.T) {
+	a := Archive{Name: "张三", IDCard: "110101199003074514", Amount: NewMoney(decimal.NewFromInt(100000)), ContractNo: "HT001"}
+	m := a.Masked()
+	if m.Name != "张*" || m.IDCard != "110101********4514" || m.Amount.String() != "****" || m.ContractNo != "HT001" {
+		t.Fatalf("unexpected masked archive: %+v", m)
+	}
+	if a.Name != "张三" {
+		t.Fatal("Masked should not modify the original")
+	}
+}
@@ -0,0 +1,211 @@
+package archive
+
+import (
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OCR 识别状态
+const (
+	OCRPending    = "pending"
+	OCRProcessing = "processing"
+	OCRDone       = "done"
+	OCRFailed     = "failed"
+)
+
+// AttachmentText 附件当前版本的 OCR 识别结果，同时作为识别任务队列
+// content 上建有 ngram 全文索引，用于按扫描件中的文字检索档案
+type AttachmentText struct {
+	AttachmentID uint      `gorm:"primaryKey;autoIncrement:false;comment:'附件ID'" json:"attachment_id"`
+	ArchiveID    uint      `gorm:"column:archive_id;index;comment:'档案ID'" json:"archive_id"`
+	Version      int       `gorm:"column:version;comment:'识别的附件版本'" json:"version"`
+	Status       string    `gorm:"column:status;size:16;index;comment:'识别状态'" json:"status"`
+	Content      string    `gorm:"column:content;type:longtext;comment:'识别出的文字'" json:"content"`
+	Error        string    `gorm:"column:error;type:text;comment:'识别失败原因'" json:"error,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// queueOCR 附件上传新版本后重新排队识别，旧版本的识别结果作废
+func queueOCR(tx *gorm.DB, att *Attachment) error {
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"archive_id", "version", "status", "content", "error", "updated_at"}),
+	}).Create(&AttachmentText{
+		AttachmentID: att.ID,
+		ArchiveID:    att.ArchiveID,
+		Version:      att.Version,
+		Status:       OCRPending,
+	}).Error
+}
+
+// ClaimOCRTask 领取一个待识别的任务并标记为识别中，没有任务时返回 nil
+// 使用 SKIP LOCKED，多个识别进程不会领取到同一个任务
+func ClaimOCRTask(DB *gorm.DB) (*AttachmentText, error) {
+	var task AttachmentText
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", OCRPending).Order("updated_at").First(&task).Error; err != nil {
+			return err
+		}
+		task.Status = OCRProcessing
+		return tx.Model(&task).Update("status", OCRProcessing).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// FinishOCRTask 保存识别结果，识别期间附件已上传新版本时丢弃结果
+func FinishOCRTask(DB *gorm.DB, task *AttachmentText, content string, recognizeErr error) error {
+	updates := map[string]interface{}{"status": OCRDone, "content": strings.TrimSpace(content), "error": ""}
+	if recognizeErr != nil {
+		updates = map[string]interface{}{"status": OCRFailed, "content": "", "error": recognizeErr.Error()}
+	}
+	return DB.Model(&AttachmentText{}).
+		Where("attachment_id = ? AND version = ? AND status = ?", task.AttachmentID, task.Version, OCRProcessing).
+		Updates(updates).Error
+}
+
+// ResetOCRTasks 将中断的识别任务重新排队，启动识别进程前调用
+func ResetOCRTasks(DB *gorm.DB) error {
+	return DB.Model(&AttachmentText{}).Where("status = ?", OCRProcessing).Update("status", OCRPending).Error
+}
+
+// RetryOCR 重新识别附件的当前版本
+func RetryOCR(DB *gorm.DB, att *Attachment) error {
+	return queueOCR(DB, att)
+}
+
+// FulltextQuery 将检索词转为 BOOLEAN MODE 查询，每个词作为短语且必须全部出现
+// 去掉引号等运算符，用户输入不会改变查询语义
+func FulltextQuery(q string) string {
+	terms := SearchTerms(q)
+	for i, term := range terms {
+		terms[i] = `+"` + term + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchTerms 拆分检索词，去掉全文检索的运算符
+func SearchTerms(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`"+-<>()~*@`, r)
+	})
+}
+
+// ByText 按附件识别出的文字筛选档案
+func ByText(q string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		query := FulltextQuery(q)
+		if query == "" {
+			return db
+		}
+		return db.Where("id IN (SELECT archive_id FROM attachment_texts WHERE MATCH(content) AGAINST (? IN BOOLEAN MODE))", query)
+	}
+}
+
+// TextHit 档案附件中的全文检索命中
+type TextHit struct {
+	AttachmentID uint     `json:"attachment_id"`
+	Snippets     []string `json:"snippets"`
+}
+
+// TextHits 查询档案中命中检索词的附件及高亮片段，按档案ID分组
+func TextHits(DB *gorm.DB, archiveIDs []uint, q string) (map[uint][]TextHit, error) {
+	hits := make(map[uint][]TextHit)
+	query := FulltextQuery(q)
+	if query == "" || len(archiveIDs) == 0 {
+		return hits, nil
+	}
+
+	var texts []AttachmentText
+	if err := DB.Where("archive_id IN ? AND MATCH(content) AGAINST (? IN BOOLEAN MODE)", archiveIDs, query).
+		Order("attachment_id").Find(&texts).Error; err != nil {
+		return nil, err
+	}
+	terms := SearchTerms(q)
+	for _, t := range texts {
+		hits[t.ArchiveID] = append(hits[t.ArchiveID], TextHit{
+			AttachmentID: t.AttachmentID,
+			Snippets:     Highlight(t.Content, terms, 30, 3),
+		})
+	}
+	return hits, nil
+}
+
+// Highlight 截取文字中检索词附近的片段，命中处用 <em> 标记，其余内容已做 HTML 转义
+// radius 为命中前后保留的字数，最多返回 limit 个片段，忽略大小写
+func Highlight(content string, terms []string, radius, limit int) []string {
+	text := []rune(content)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var hits [][2]int
+	for _, term := range terms {
+		t := []rune(term)
+		for i := range t {
+			t[i] = unicode.ToLower(t[i])
+		}
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == string(t) {
+				hits = append(hits, [2]int{i, i + len(t)})
+				i += len(t) - 1
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+
+	// 合并重叠的命中
+	sort.Slice(hits, func(i, j int) bool { return hits[i][0] < hits[j][0] })
+	merged := hits[:1]
+	for _, h := range hits[1:] {
+		last := &merged[len(merged)-1]
+		if h[0] <= last[1] {
+			last[1] = max(last[1], h[1])
+			continue
+		}
+		merged = append(merged, h)
+	}
+
+	var snippets []string
+	for i := 0; i < len(merged) && len(snippets) < limit; {
+		from := max(merged[i][0]-radius, 0)
+		to := min(merged[i][1]+radius, len(text))
+
+		var b strings.Builder
+		if from > 0 {
+			b.WriteString("…")
+		}
+		pos := from
+		// 片段范围内的命中合并为一个片段
+		for ; i < len(merged) && merged[i][0] < to; i++ {
+			b.WriteString(html.EscapeString(string(text[pos:merged[i][0]])))
+			b.WriteString("<em>" + html.EscapeString(string(text[merged[i][0]:merged[i][1]])) + "</em>")
+			pos = merged[i][1]
+			to = max(to, min(merged[i][1]+radius, len(text)))
+		}
+		b.WriteString(html.EscapeString(string(text[pos:to])))
+		if to < len(text) {
+			b.WriteString("…")
+		}
+		snippets = append(snippets, strings.Join(strings.Fields(b.String()), " "))
+	}
+	return snippets
+}
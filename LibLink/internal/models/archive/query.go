@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"gorm.io/gorm"
+)
+
+// Query 档案列表与检索的筛选条件，可保存为常用查询
+type Query struct {
+	ContractNo  string      `json:"contract_no,omitempty" form:"contract_no"`
+	IDCard      string      `json:"id_card,omitempty" form:"id_card"`
+	ArcType     string      `json:"arc_type,omitempty" form:"arc_type"`
+	InstNo      string      `json:"inst_no,omitempty" form:"inst_no"`
+	BorrowState string      `json:"borrow_state,omitempty" form:"borrow_state"`
+	AmountMin   string      `json:"amount_min,omitempty" form:"amount_min"`
+	AmountMax   string      `json:"amount_max,omitempty" form:"amount_max"`
+	DateFrom    string      `json:"storage_date_from,omitempty" form:"storage_date_from"`
+	DateTo      string      `json:"storage_date_to,omitempty" form:"storage_date_to"`
+	LocationID  uint        `json:"location_id,omitempty" form:"location_id"` // 存放的档案盒
+	Text        string      `json:"text,omitempty" form:"text"`               // 附件识别出的文字
+	Extra       ExtraFilter `json:"extra,omitempty" form:"-"`                 // 自定义字段条件，需要同时指定档案类型
+}
+
+// Scope 生成筛选条件，取值无效时记录到校验错误，此时不应执行查询
+func (q *Query) Scope(DB *gorm.DB, v *Validator) (func(db *gorm.DB) *gorm.DB, error) {
+	// 金额与入库日期范围
+	amountMin := v.Amount("amount", q.AmountMin)
+	amountMax := v.Amount("amount", q.AmountMax)
+	dateFrom := v.Date("storage_date", q.DateFrom)
+	dateTo := v.Date("storage_date", q.DateTo)
+
+	// 自定义字段筛选需要指定档案类型，按该类型的字段定义解析
+	var extraScope func(db *gorm.DB) *gorm.DB
+	if !q.Extra.Empty() {
+		types, err := LoadArchiveTypes(DB, q.ArcType)
+		if err != nil {
+			return nil, err
+		}
+		if arcType, ok := types[q.ArcType]; ok {
+			extraScope = v.ExtraScope(arcType, q.Extra)
+		} else {
+			v.ArcType(types, q.ArcType)
+		}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		if q.ContractNo != "" {
+			db = db.Where("contract_no LIKE ?", "%"+q.ContractNo+"%")
+		}
+		// 身份证号加密存储，只支持精确匹配
+		if q.IDCard != "" {
+			db = db.Scopes(ByIDCard(q.IDCard))
+		}
+		if q.ArcType != "" {
+			db = db.Where("arc_type = ?", q.ArcType)
+		}
+		if q.InstNo != "" {
+			db = db.Where("inst_no = ?", q.InstNo)
+		}
+		if q.BorrowState != "" {
+			db = db.Where("borrow_state = ?", q.BorrowState)
+		}
+		if q.LocationID != 0 {
+			db = db.Where("location_id = ?", q.LocationID)
+		}
+		if q.Text != "" {
+			db = db.Scopes(ByText(q.Text))
+		}
+		if amountMin.Valid {
+			db = db.Where("amount >= ?", amountMin)
+		}
+		if amountMax.Valid {
+			db = db.Where("amount <= ?", amountMax)
+		}
+		if dateFrom.Valid {
+			db = db.Where("storage_date >= ?", dateFrom)
+		}
+		if dateTo.Valid {
+			db = db.Where("storage_date <= ?", dateTo)
+		}
+		if extraScope != nil {
+			db = db.Scopes(extraScope)
+		}
+		return db
+	}, nil
+}
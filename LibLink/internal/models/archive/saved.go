@@ -0,0 +1,49 @@
+package archive
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavedSearch 保存的档案查询，Q 不为空时按检索执行，否则等同于档案列表
+// SharedGroup 为空时仅创建者可见，否则该用户组的成员均可使用，执行时按使用者本人的权限筛选
+type SavedSearch struct {
+	gorm.Model
+	Name        string `gorm:"column:name;size:64;comment:'名称'" json:"name"`
+	OwnerID     string `gorm:"column:owner_id;size:255;index;comment:'创建者'" json:"owner_id"`
+	SharedGroup string `gorm:"column:shared_group;size:64;index;comment:'共享给的用户组'" json:"shared_group"`
+	Q           string `gorm:"column:q;comment:'检索词'" json:"q"`
+	Query       Query  `gorm:"column:query;type:text;serializer:json;comment:'筛选条件,JSON'" json:"query"`
+	Sort        string `gorm:"column:sort;size:32;comment:'排序字段'" json:"sort"`
+	Order       string `gorm:"column:sort_order;size:8;comment:'排序方向'" json:"order"`
+}
+
+// VisibleTo 用户自己创建或共享给其所在用户组的查询
+func VisibleTo(email, permissionGroup string) func(db *gorm.DB) *gorm.DB {
+	var groups []string
+	for _, g := range strings.Split(permissionGroup, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if len(groups) == 0 {
+			return db.Where("owner_id = ?", email)
+		}
+		return db.Where("owner_id = ? OR shared_group IN ?", email, groups)
+	}
+}
+
+// Subscription 订阅保存的查询，有新档案匹配或匹配档案的借阅状态变化时邮件通知订阅者
+type Subscription struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	SavedSearchID uint      `gorm:"column:saved_search_id;uniqueIndex:idx_subscription,priority:1;comment:'保存的查询ID'" json:"saved_search_id"`
+	UserID        string    `gorm:"column:user_id;size:255;uniqueIndex:idx_subscription,priority:2;comment:'订阅者'" json:"user_id"`
+	NotifyNew     bool      `gorm:"column:notify_new;comment:'有新档案匹配时通知'" json:"notify_new"`
+	NotifyBorrow  bool      `gorm:"column:notify_borrow;comment:'匹配档案借阅状态变化时通知'" json:"notify_borrow"`
+	CheckedAt     time.Time `gorm:"column:checked_at;comment:'上次检查时间,之后的变化在下次检查时通知'" json:"checked_at"`
+}
@@ -0,0 +1,193 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultFileNoTemplate 默认档案编号格式，与历史编号保持一致
+const DefaultFileNoTemplate = "{seq}"
+
+// ArchiveSequence 按档案类型与周期递增的档案编号序列
+type ArchiveSequence struct {
+	ArcType string `gorm:"column:arc_type;size:64;primaryKey;comment:'档案类型'" json:"arc_type"`
+	Period  string `gorm:"column:period;size:16;primaryKey;comment:'编号周期,模板含年份或月份时按周期重新计数'" json:"period"`
+	Value   uint64 `gorm:"column:value;comment:'当前已分配的最大序号'" json:"value"`
+}
+
+// 支持的占位符：{type} 档案类型，{yyyy}/{yy} 年份，{mm} 月份，{seq} 或 {seq:06} 序号（可指定补零位数）
+var templateToken = regexp.MustCompile(`\{(type|yyyy|yy|mm|seq(?::(\d+))?)\}`)
+
+// ValidateFileNoTemplate 检查编号模板，必须包含 {seq} 且不含未知占位符
+// 含 {mm} 时必须同时含年份，否则次年同月会生成相同的编号
+func ValidateFileNoTemplate(template string) error {
+	if !strings.Contains(template, "{seq") {
+		return errors.New("档案编号模板必须包含 {seq}")
+	}
+	if strings.Contains(template, "{mm}") && !hasYearToken(template) {
+		return errors.New("档案编号模板包含 {mm} 时必须同时包含 {yyyy} 或 {yy}")
+	}
+	rest := templateToken.ReplaceAllString(template, "")
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("档案编号模板包含未知占位符: %s", template)
+	}
+	return nil
+}
+
+// FileNoPeriod 编号周期，模板包含年份或月份时序号按周期重新计数
+// 周期只由模板中出现的占位符决定，编号中不体现的部分不参与重新计数
+func FileNoPeriod(template string, t time.Time) string {
+	if !hasYearToken(template) {
+		return ""
+	}
+	if strings.Contains(template, "{mm}") {
+		return t.Format("200601")
+	}
+	return t.Format("2006")
+}
+
+func hasYearToken(template string) bool {
+	return strings.Contains(template, "{yyyy}") || strings.Contains(template, "{yy}")
+}
+
+// FormatFileNo 按模板生成档案编号
+func FormatFileNo(template, arcType string, t time.Time, seq uint64) string {
+	return templateToken.ReplaceAllStringFunc(template, func(token string) string {
+		m := templateToken.FindStringSubmatch(token)
+		switch {
+		case m[1] == "type":
+			return arcType
+		case m[1] == "yyyy":
+			return t.Format("2006")
+		case m[1] == "yy":
+			return t.Format("06")
+		case m[1] == "mm":
+			return t.Format("01")
+		case m[2] != "":
+			width, _ := strconv.Atoi(m[2])
+			return fmt.Sprintf("%0*d", width, seq)
+		default:
+			return strconv.FormatUint(seq, 10)
+		}
+	})
+}
+
+// NextFileNos 在事务中为指定档案类型分配 n 个连续编号
+// 使用 SELECT ... FOR UPDATE 锁定序列行，并发导入时不会重复
+func NextFileNos(DB *gorm.DB, template, arcType string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	period := FileNoPeriod(template, now)
+
+	var first uint64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 序列行不存在时先插入，已存在则忽略
+		seq := ArchiveSequence{ArcType: arcType, Period: period}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("arc_type = ? AND period = ?", arcType, period).First(&seq).Error; err != nil {
+			return err
+		}
+
+		first = seq.Value + 1
+		return tx.Model(&ArchiveSequence{}).Where("arc_type = ? AND period = ?", arcType, period).
+			Update("value", seq.Value+uint64(n)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fileNos := make([]string, n)
+	for i := range fileNos {
+		fileNos[i] = FormatFileNo(template, arcType, now, first+uint64(i))
+	}
+	return fileNos, nil
+}
+
+// NextFileNo 为指定档案类型分配一个编号
+func NextFileNo(DB *gorm.DB, template, arcType string) (string, error) {
+	fileNos, err := NextFileNos(DB, template, arcType, 1)
+	if err != nil {
+		return "", err
+	}
+	return fileNos[0], nil
+}
+
+// InitSequences 按已有的纯数字档案编号初始化各类型的序列，已存在的序列不变
+func InitSequences(DB *gorm.DB) error {
+	type typeMax struct {
+		ArcType string
+		MaxNo   uint64
+	}
+	var rows []typeMax
+	if err := DB.Model(&Archive{}).Unscoped().
+		Select("arc_type, MAX(CAST(file_no AS UNSIGNED)) AS max_no").
+		Where("file_no REGEXP '^[0-9]+$'").
+		Group("arc_type").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, r := range rows {
+		seq := ArchiveSequence{ArcType: r.ArcType, Period: "", Value: r.MaxNo}
+		if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenumberDuplicateFileNos 为同类型下重复或为空的档案编号重新分配序号，保留最早的一条
+// 原编号记录到 legacy_values，返回被修改的记录
+func RenumberDuplicateFileNos(DB *gorm.DB) ([]LegacyValue, error) {
+	type dup struct {
+		ArcType string
+		FileNo  string
+	}
+	var dups []dup
+	if err := DB.Model(&Archive{}).Unscoped().
+		Select("arc_type, file_no").
+		Group("arc_type, file_no").
+		Having("COUNT(*) > 1 OR file_no = ''").
+		Scan(&dups).Error; err != nil {
+		return nil, err
+	}
+
+	var changed []LegacyValue
+	for _, d := range dups {
+		var ids []uint
+		if err := DB.Model(&Archive{}).Unscoped().
+			Where("arc_type = ? AND file_no = ?", d.ArcType, d.FileNo).
+			Order("id").Pluck("id", &ids).Error; err != nil {
+			return changed, err
+		}
+		if d.FileNo != "" {
+			ids = ids[1:]
+		}
+
+		fileNos, err := NextFileNos(DB, DefaultFileNoTemplate, d.ArcType, len(ids))
+		if err != nil {
+			return changed, err
+		}
+		for i, id := range ids {
+			if err := DB.Model(&Archive{}).Unscoped().Where("id = ?", id).UpdateColumn("file_no", fileNos[i]).Error; err != nil {
+				return changed, err
+			}
+			lv := LegacyValue{Table: "archives", RowID: id, Column: "file_no", Value: d.FileNo}
+			if err := DB.Create(&lv).Error; err != nil {
+				return changed, err
+			}
+			changed = append(changed, lv)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,52 @@
+package archive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateFileNoTemplate(t *testing.T) {
+	for _, tpl := range []string{"{seq}", "{type}-{yyyy}-{seq:06}", "A{yy}{mm}{seq:4}"} {
+		if err := ValidateFileNoTemplate(tpl); err != nil {
+			t.Errorf("ValidateFileNoTemplate(%q) = %v", tpl, err)
+		}
+	}
+	for _, tpl := range []string{"", "{type}-{yyyy}", "{seq}-{dd}", "{seq", "{type}{mm}-{seq}"} {
+		if err := ValidateFileNoTemplate(tpl); err == nil {
+			t.Errorf("ValidateFileNoTemplate(%q) should fail", tpl)
+		}
+	}
+}
+
+func TestFormatFileNo(t *testing.T) {
+	now := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{"{seq}", "42"},
+		{"{type}-{yyyy}-{seq:06}", "LOAN-2024-000042"},
+		{"{yy}{mm}{seq:3}", "2403042"},
+	}
+	for _, c := range cases {
+		if got := FormatFileNo(c.template, "LOAN", now, 42); got != c.want {
+			t.Errorf("FormatFileNo(%q) = %q, want %q", c.template, got, c.want)
+		}
+	}
+}
+
+func TestFileNoPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)
+	cases := map[string]string{
+		"{seq}":            "",
+		"{type}-{seq:06}":  "",
+		"{yyyy}-{seq}":     "2024",
+		"{yy}{mm}-{seq}":   "202403",
+		"{type}{mm}-{seq}": "",
+	}
+	for tpl, want := range cases {
+		if got := FileNoPeriod(tpl, now); got != want {
+			t.Errorf("FileNoPeriod(%q) = %q, want %q", tpl, got, want)
+		}
+	}
+}
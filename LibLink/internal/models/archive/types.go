@@ -0,0 +1,269 @@
+package archive
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Money 金额，数据库中为 DECIMAL(18,2)，JSON 中为保留两位小数的字符串，空值为 ""
+// 无法解析的输入保留原文，由 Validator 报告
+type Money struct {
+	Decimal decimal.Decimal
+	Valid   bool
+	raw     string
+}
+
+func NewMoney(d decimal.Decimal) Money {
+	return Money{Decimal: d.Round(2), Valid: true}
+}
+
+// ParseMoney 解析金额文本，失败时返回携带原文的无效值
+func ParseMoney(value string) (Money, string) {
+	if value == "" {
+		return Money{}, ""
+	}
+	d, code := parseAmount(value)
+	if code != "" {
+		return Money{raw: value}, code
+	}
+	return NewMoney(d), ""
+}
+
+// String 规范形式，无效值返回原文
+func (m Money) String() string {
+	if !m.Valid {
+		return m.raw
+	}
+	return m.Decimal.StringFixed(2)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// 兼容数字形式
+		s = string(data)
+	}
+	*m, _ = ParseMoney(s)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	if !m.Valid {
+		return nil, nil
+	}
+	return m.Decimal.StringFixed(2), nil
+}
+
+func (m *Money) Scan(value interface{}) error {
+	if value == nil {
+		*m = Money{}
+		return nil
+	}
+	var d decimal.NullDecimal
+	if err := d.Scan(value); err != nil {
+		return err
+	}
+	*m = Money{Decimal: d.Decimal, Valid: d.Valid}
+	return nil
+}
+
+func (Money) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "DECIMAL(18,2)"
+}
+
+// Date 日期，数据库中为 DATE，空值为 ""
+// JSON 沿用字符串字段时的 2006-01-02T15:04:05.000Z 格式，值为本地零点对应的 UTC 时间
+type Date struct {
+	Time  time.Time
+	Valid bool
+	raw   string
+}
+
+func NewDate(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Time: time.Date(y, m, d, 0, 0, 0, 0, time.Local), Valid: true}
+}
+
+// Today 本地时间的今天
+func Today() Date {
+	return NewDate(time.Now())
+}
+
+// ParseDateValue 解析日期文本，失败时返回携带原文的无效值
+func ParseDateValue(value string) (Date, bool) {
+	if value == "" {
+		return Date{}, true
+	}
+	t, err := ParseDate(value)
+	if err != nil {
+		return Date{raw: value}, false
+	}
+	return NewDate(t), true
+}
+
+func (d Date) String() string {
+	if !d.Valid {
+		return d.raw
+	}
+	return d.Time.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if !d.Valid {
+		return json.Marshal(d.raw)
+	}
+	return json.Marshal(d.Time.UTC().Format(DateTimeLayout))
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Date{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("date must be a string: %w", err)
+	}
+	*d, _ = ParseDateValue(s)
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	if !d.Valid {
+		return nil, nil
+	}
+	return d.Time.Format(DateLayout), nil
+}
+
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = NewDate(v)
+	case []byte:
+		*d, _ = ParseDateValue(string(v))
+	case string:
+		*d, _ = ParseDateValue(v)
+	default:
+		return fmt.Errorf("unsupported date type %T", value)
+	}
+	return nil
+}
+
+func (Date) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "DATE"
+}
+
+// DateTimeLayout 时间的 JSON 格式，与历史数据保持一致
+const DateTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// DateTime 时间，数据库中为 DATETIME(3) 并统一存储 UTC，JSON 中为 2006-01-02T15:04:05.000Z
+type DateTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func NewDateTime(t time.Time) DateTime {
+	return DateTime{Time: t.UTC().Truncate(time.Millisecond), Valid: true}
+}
+
+func Now() DateTime {
+	return NewDateTime(time.Now())
+}
+
+func (t DateTime) String() string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.UTC().Format(DateTimeLayout)
+}
+
+func (t DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *DateTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || s == "" {
+		*t = DateTime{}
+		return nil
+	}
+	parsed, err := ParseDateTime(s)
+	if err != nil {
+		return err
+	}
+	*t = NewDateTime(parsed)
+	return nil
+}
+
+func (t DateTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	// 以 UTC 墙上时间写入，与连接的时区设置无关
+	return t.Time.UTC().Format("2006-01-02 15:04:05.000"), nil
+}
+
+func (t *DateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = DateTime{}
+	case time.Time:
+		// parseTime 按 UTC 解析 DATETIME
+		*t = NewDateTime(time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC))
+	case []byte, string:
+		s := fmt.Sprint(v)
+		if b, ok := v.([]byte); ok {
+			s = string(b)
+		}
+		parsed, err := ParseDateTime(s)
+		if err != nil {
+			return err
+		}
+		*t = NewDateTime(parsed)
+	default:
+		return fmt.Errorf("unsupported datetime type %T", value)
+	}
+	return nil
+}
+
+func (DateTime) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return "DATETIME(3)"
+}
+
+var dateTimeLayouts = []string{
+	DateTimeLayout,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04:05",
+}
+
+// ParseDateTime 解析历史时间文本，不带时区的按 UTC 处理；仅有日期时取当天零点
+func ParseDateTime(value string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	t, err := ParseDate(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
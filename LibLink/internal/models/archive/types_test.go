@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	var a struct {
+		Amount Money `json:"amount"`
+	}
+	for in, want := range map[string]string{
+		`{"amount":"1,000.5"}`: `{"amount":"1000.50"}`,
+		`{"amount":1234}`:      `{"amount":"1234.00"}`,
+		`{"amount":""}`:        `{"amount":""}`,
+		`{"amount":null}`:      `{"amount":""}`,
+		`{"amount":"十万"}`:      `{"amount":"十万"}`,
+	} {
+		a.Amount = Money{}
+		if err := json.Unmarshal([]byte(in), &a); err != nil {
+			t.Fatalf("unmarshal %s: %v", in, err)
+		}
+		out, _ := json.Marshal(a)
+		if string(out) != want {
+			t.Errorf("%s -> %s, want %s", in, out, want)
+		}
+	}
+
+	v, _ := Money{raw: "十万"}.Value()
+	if v != nil {
+		t.Errorf("invalid money should be stored as NULL, got %v", v)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("1234.50")); err != nil || m.String() != "1234.50" {
+		t.Fatalf("scan = %q, %v", m.String(), err)
+	}
+	if err := m.Scan(nil); err != nil || m.Valid {
+		t.Fatalf("scan nil = %+v, %v", m, err)
+	}
+}
+
+func TestDateScanAndValue(t *testing.T) {
+	var d Date
+	if err := d.Scan(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)); err != nil || d.String() != "2024-03-05" {
+		t.Fatalf("scan time = %q, %v", d.String(), err)
+	}
+	if v, _ := d.Value(); v != "2024-03-05" {
+		t.Fatalf("value = %v", v)
+	}
+	out, _ := json.Marshal(Date{})
+	if string(out) != `""` {
+		t.Fatalf("empty date json = %s", out)
+	}
+}
+
+func TestDateLegacyJSON(t *testing.T) {
+	d := NewDate(time.Date(2024, 3, 5, 15, 0, 0, 0, time.Local))
+	out, _ := json.Marshal(d)
+	want := time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local).UTC().Format(DateTimeLayout)
+	if string(out) != `"`+want+`"` {
+		t.Fatalf("json = %s, want %s", out, want)
+	}
+
+	var back Date
+	if err := json.Unmarshal(out, &back); err != nil || back.String() != "2024-03-05" {
+		t.Fatalf("unmarshal = %q, %v", back.String(), err)
+	}
+}
+
+func TestDateTimeLegacyFormat(t *testing.T) {
+	parsed, err := ParseDateTime("2024-03-05T08:30:00.123Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dt := NewDateTime(parsed)
+	out, _ := json.Marshal(dt)
+	if string(out) != `"2024-03-05T08:30:00.123Z"` {
+		t.Fatalf("json = %s", out)
+	}
+
+	var scanned DateTime
+	if err := scanned.Scan(time.Date(2024, 3, 5, 8, 30, 0, 123e6, time.UTC)); err != nil || scanned.String() != dt.String() {
+		t.Fatalf("scan = %q, %v", scanned.String(), err)
+	}
+	if v, _ := dt.Value(); v != "2024-03-05 08:30:00.123" {
+		t.Fatalf("value = %v", v)
+	}
+}
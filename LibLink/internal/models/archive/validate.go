@@ -0,0 +1,320 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"liblink/pkg/fieldcrypt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// 校验错误码
+const (
+	ErrCodeRequired         = "required"
+	ErrCodeIDCardFormat     = "id_card_format"
+	ErrCodeIDCardBirth      = "id_card_birth"
+	ErrCodeIDCardChecksum   = "id_card_checksum"
+	ErrCodeAmountFormat     = "amount_format"
+	ErrCodeAmountNegative   = "amount_negative"
+	ErrCodeAmountPrecision  = "amount_precision"
+	ErrCodeDateFormat       = "date_format"
+	ErrCodeContractNoExists = "contract_no_exists"
+	ErrCodeContractNoRepeat = "contract_no_repeat"
+	ErrCodeArcTypeUnknown   = "arc_type_unknown"
+	ErrCodeFieldUnknown     = "field_unknown"
+	ErrCodeFieldType        = "field_type"
+	ErrCodeFieldOption      = "field_option"
+	ErrCodeFieldRange       = "field_range"
+)
+
+// validationMessages 校验错误的本地化文案，%s 为字段名
+var validationMessages = map[string]map[string]string{
+	"zh": {
+		ErrCodeRequired:         "%s不能为空",
+		ErrCodeIDCardFormat:     "%s必须为 18 位居民身份证号",
+		ErrCodeIDCardBirth:      "%s中的出生日期无效",
+		ErrCodeIDCardChecksum:   "%s校验位错误",
+		ErrCodeAmountFormat:     "%s不是有效的金额",
+		ErrCodeAmountNegative:   "%s不能为负数",
+		ErrCodeAmountPrecision:  "%s最多保留两位小数",
+		ErrCodeDateFormat:       "%s不是有效的日期",
+		ErrCodeContractNoExists: "%s已存在",
+		ErrCodeContractNoRepeat: "%s在导入文件中重复",
+		ErrCodeArcTypeUnknown:   "%s未登记或已停用",
+		ErrCodeFieldUnknown:     "%s不是该档案类型的字段",
+		ErrCodeFieldType:        "%s的取值格式不正确",
+		ErrCodeFieldOption:      "%s不在可选范围内",
+		ErrCodeFieldRange:       "%s不支持范围查询",
+	},
+	"en": {
+		ErrCodeRequired:         "%s is required",
+		ErrCodeIDCardFormat:     "%s must be an 18-digit resident ID number",
+		ErrCodeIDCardBirth:      "%s contains an invalid birth date",
+		ErrCodeIDCardChecksum:   "%s has an invalid check digit",
+		ErrCodeAmountFormat:     "%s is not a valid amount",
+		ErrCodeAmountNegative:   "%s must not be negative",
+		ErrCodeAmountPrecision:  "%s allows at most two decimal places",
+		ErrCodeDateFormat:       "%s is not a valid date",
+		ErrCodeContractNoExists: "%s already exists",
+		ErrCodeContractNoRepeat: "%s is duplicated in the import file",
+		ErrCodeArcTypeUnknown:   "%s is not registered or has been disabled",
+		ErrCodeFieldUnknown:     "%s is not a field of this archive type",
+		ErrCodeFieldType:        "%s has an invalid value",
+		ErrCodeFieldOption:      "%s is not one of the allowed options",
+		ErrCodeFieldRange:       "%s does not support range filters",
+	},
+}
+
+var fieldNames = map[string]map[string]string{
+	"zh": {
+		"contract_no":  "合同编号",
+		"name":         "姓名",
+		"id_card":      "身份证号",
+		"amount":       "合同金额",
+		"storage_date": "入库日期",
+		"arc_type":     "档案类型",
+	},
+	"en": {
+		"contract_no":  "Contract number",
+		"name":         "Name",
+		"id_card":      "ID card number",
+		"amount":       "Amount",
+		"storage_date": "Storage date",
+		"arc_type":     "Archive type",
+	},
+}
+
+// ValidationError 单个字段的校验错误，Row 为批量导入时的 Excel 行号
+// Label 为自定义字段的显示名称，内置字段为空
+type ValidationError struct {
+	Row   int    `json:"row,omitempty"`
+	Field string `json:"field"`
+	Label string `json:"-"`
+	Code  string `json:"code"`
+	Value string `json:"-"`
+}
+
+// Message 本地化的错误描述，lang 为 zh 或 en
+func (e ValidationError) Message(lang string) string {
+	msgs, ok := validationMessages[lang]
+	if !ok {
+		lang, msgs = "zh", validationMessages["zh"]
+	}
+	field := fieldNames[lang][e.Field]
+	if field == "" {
+		field = e.Label
+	}
+	if field == "" {
+		field = e.Field
+	}
+	msg := fmt.Sprintf(msgs[e.Code], field)
+	if e.Row > 0 {
+		if lang == "en" {
+			return fmt.Sprintf("row %d: %s", e.Row, msg)
+		}
+		return fmt.Sprintf("第%d行：%s", e.Row, msg)
+	}
+	return msg
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Message("zh"))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validator 校验并规范化档案字段，错误累积到 Errors 中
+type Validator struct {
+	Row    int
+	Errors ValidationErrors
+}
+
+func (v *Validator) fail(field, code, value string) {
+	v.Errors = append(v.Errors, ValidationError{Row: v.Row, Field: field, Code: code, Value: value})
+}
+
+// Valid 是否没有校验错误
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// Required 去除首尾空白并检查非空
+func (v *Validator) Required(field, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		v.fail(field, ErrCodeRequired, value)
+	}
+	return value
+}
+
+// IDCard 校验 18 位居民身份证号，返回大写规范形式，空值不校验
+func (v *Validator) IDCard(field, value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return value
+	}
+	if code := checkIDCard(value); code != "" {
+		v.fail(field, code, value)
+	}
+	return value
+}
+
+// Amount 解析金额，空值不校验
+func (v *Validator) Amount(field, value string) Money {
+	value = strings.TrimSpace(value)
+	m, code := ParseMoney(value)
+	if code != "" {
+		v.fail(field, code, value)
+	}
+	return m
+}
+
+// Date 解析日期，空值不校验
+func (v *Validator) Date(field, value string) Date {
+	value = strings.TrimSpace(value)
+	d, ok := ParseDateValue(value)
+	if !ok {
+		v.fail(field, ErrCodeDateFormat, value)
+	}
+	return d
+}
+
+// ContractNoUnique 检查合同编号是否已被其他档案使用，excludeID 为当前档案ID
+// 唯一索引包含已删除的档案，因此一并检查
+func (v *Validator) ContractNoUnique(DB *gorm.DB, contractNo string, excludeID uint) error {
+	if contractNo == "" {
+		return nil
+	}
+	var count int64
+	if err := DB.Model(&Archive{}).Unscoped().Where("contract_no = ? AND id <> ?", contractNo, excludeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		v.fail("contract_no", ErrCodeContractNoExists, contractNo)
+	}
+	return nil
+}
+
+// Validate 校验新增档案的全部字段并就地规范化
+func (a *Archive) Validate(v *Validator) {
+	a.ContractNo = v.Required("contract_no", a.ContractNo)
+	a.IDCard = fieldcrypt.String(v.IDCard("id_card", string(a.IDCard)))
+	a.Amount = v.Amount("amount", a.Amount.String())
+	a.StorageDate = v.Date("storage_date", a.StorageDate.String())
+}
+
+// ContractNosExisting 返回已存在的合同编号，用于批量导入
+func ContractNosExisting(DB *gorm.DB, contractNos []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(contractNos) == 0 {
+		return existing, nil
+	}
+	var found []string
+	if err := DB.Model(&Archive{}).Unscoped().Where("contract_no IN ?", contractNos).Pluck("contract_no", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, no := range found {
+		existing[no] = true
+	}
+	return existing, nil
+}
+
+// DateLayout 日期的规范存储格式
+const DateLayout = "2006-01-02"
+
+var dateLayouts = []string{
+	DateLayout,
+	"2006/01/02",
+	"2006.01.02",
+	"20060102",
+	"2006-1-2",
+	"2006/1/2",
+	"2006年1月2日",
+	"2006-01-02 15:04:05",
+}
+
+// utcLayouts 带时区的写法，转换为本地日期
+var utcLayouts = []string{
+	"2006-01-02T15:04:05.000Z",
+	time.RFC3339,
+}
+
+// ParseDate 解析常见的日期写法，包括 Excel 日期序列号
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range utcLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.In(time.Local), nil
+		}
+	}
+
+	// Excel 单元格未设置日期格式时读取到的是 1899-12-30 起的天数
+	if serial, err := strconv.Atoi(value); err == nil && serial > 0 && serial < 100000 && len(value) != 8 {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local).AddDate(0, 0, serial), nil
+	}
+	return time.Time{}, errors.New("invalid date: " + value)
+}
+
+var (
+	idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	idCardChecks  = "10X98765432"
+)
+
+// checkIDCard 校验 GB 11643 居民身份证号，返回错误码
+func checkIDCard(id string) string {
+	if len(id) != 18 {
+		return ErrCodeIDCardFormat
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return ErrCodeIDCardFormat
+		}
+		sum += int(id[i]-'0') * idCardWeights[i]
+	}
+	if last := id[17]; (last < '0' || last > '9') && last != 'X' {
+		return ErrCodeIDCardFormat
+	}
+	if id[0] < '1' || id[0] > '9' {
+		return ErrCodeIDCardFormat
+	}
+
+	birth, err := time.ParseInLocation("20060102", id[6:14], time.Local)
+	if err != nil || birth.Year() < 1900 || birth.After(time.Now()) {
+		return ErrCodeIDCardBirth
+	}
+
+	if idCardChecks[sum%11] != id[17] {
+		return ErrCodeIDCardChecksum
+	}
+	return ""
+}
+
+// parseAmount 解析金额，允许千分位分隔符
+func parseAmount(value string) (decimal.Decimal, string) {
+	cleaned := strings.NewReplacer(",", "", "，", "", " ", "").Replace(value)
+	d, err := decimal.NewFromString(cleaned)
+	if err != nil {
+		return decimal.Zero, ErrCodeAmountFormat
+	}
+	if d.IsNegative() {
+		return decimal.Zero, ErrCodeAmountNegative
+	}
+	if d.Exponent() < -2 && !d.Equal(d.Round(2)) {
+		return decimal.Zero, ErrCodeAmountPrecision
+	}
+	return d, ""
+}
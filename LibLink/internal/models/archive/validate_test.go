@@ -0,0 +1,98 @@
+package archive
+
+import "testing"
+
+func TestValidatorIDCard(t *testing.T) {
+	cases := map[string]string{
+		"11010519491231002X": "",
+		"11010519491231002x": "",
+		"110101199003074514": "",
+		"110101199003074515": ErrCodeIDCardChecksum,
+		"110101199013074514": ErrCodeIDCardBirth,
+		"11010119900307451":  ErrCodeIDCardFormat,
+		"1101011990030745A4": ErrCodeIDCardFormat,
+		"010101199003074514": ErrCodeIDCardFormat,
+	}
+	for in, want := range cases {
+		v := &Validator{}
+		v.IDCard("id_card", in)
+		got := ""
+		if !v.Valid() {
+			got = v.Errors[0].Code
+		}
+		if got != want {
+			t.Errorf("IDCard(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	v := &Validator{}
+	if got := v.IDCard("id_card", " 11010519491231002x "); got != "11010519491231002X" {
+		t.Errorf("IDCard not normalized: %q", got)
+	}
+}
+
+func TestValidatorAmount(t *testing.T) {
+	ok := map[string]string{
+		"100":         "100.00",
+		"1,234,567.8": "1234567.80",
+		" 0.5 ":       "0.50",
+		"100.000":     "100.00",
+		"":            "",
+	}
+	for in, want := range ok {
+		v := &Validator{}
+		if got := v.Amount("amount", in).String(); got != want || !v.Valid() {
+			t.Errorf("Amount(%q) = %q, %v, want %q", in, got, v.Errors, want)
+		}
+	}
+
+	bad := map[string]string{
+		"十万":    ErrCodeAmountFormat,
+		"-1":    ErrCodeAmountNegative,
+		"1.005": ErrCodeAmountPrecision,
+		"12abc": ErrCodeAmountFormat,
+	}
+	for in, want := range bad {
+		v := &Validator{}
+		v.Amount("amount", in)
+		if v.Valid() || v.Errors[0].Code != want {
+			t.Errorf("Amount(%q) errors = %v, want %q", in, v.Errors, want)
+		}
+	}
+}
+
+func TestValidatorDate(t *testing.T) {
+	ok := map[string]string{
+		"2024-03-05": "2024-03-05",
+		"2024/3/5":   "2024-03-05",
+		"2024.03.05": "2024-03-05",
+		"20240305":   "2024-03-05",
+		"2024年3月5日":  "2024-03-05",
+		"45356":      "2024-03-05",
+	}
+	for in, want := range ok {
+		v := &Validator{}
+		if got := v.Date("storage_date", in).String(); got != want || !v.Valid() {
+			t.Errorf("Date(%q) = %q, %v, want %q", in, got, v.Errors, want)
+		}
+	}
+
+	v := &Validator{}
+	v.Date("storage_date", "2024-13-01")
+	if v.Valid() {
+		t.Error("invalid month should fail")
+	}
+}
+
+func TestValidationMessage(t *testing.T) {
+	e := ValidationError{Row: 3, Field: "id_card", Code: ErrCodeIDCardChecksum}
+	if got := e.Message("zh"); got != "第3行：身份证号校验位错误" {
+		t.Errorf("zh message = %q", got)
+	}
+	if got := e.Message("en"); got != "row 3: ID card number has an invalid check digit" {
+		t.Errorf("en message = %q", got)
+	}
+	if got := e.Message("fr"); got != e.Message("zh") {
+		t.Errorf("unknown language should fall back to zh: %q", got)
+	}
+}
@@ -0,0 +1,131 @@
+package branch
+
+import (
+	"errors"
+	"liblink/internal/models/user"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Branch 网点主数据，通过 ParentNo 组成总行-支行-网点的层级
+type Branch struct {
+	gorm.Model
+	InstNo   string `gorm:"column:inst_no;size:32;uniqueIndex;comment:'网点编号'" json:"inst_no"`
+	Name     string `gorm:"column:name;comment:'网点名称'" json:"name"`
+	ParentNo string `gorm:"column:parent_no;size:32;index:idx_parent_no;comment:'上级网点编号,顶层为空'" json:"parent_no"`
+}
+
+// BranchTree 网点树形结构
+type BranchTree struct {
+	Branch   Branch       `json:"branch"`
+	Children []BranchTree `json:"children"`
+}
+
+// SplitInstNos 解析逗号分隔的网点编号，去除空值与重复值
+func SplitInstNos(instNos string) []string {
+	var result []string
+	seen := make(map[string]struct{})
+	for _, no := range strings.Split(instNos, ",") {
+		no = strings.TrimSpace(no)
+		if _, ok := seen[no]; ok || no == "" {
+			continue
+		}
+		seen[no] = struct{}{}
+		result = append(result, no)
+	}
+	return result
+}
+
+// Descendants 获取网点及其全部下级网点编号
+func Descendants(DB *gorm.DB, instNos []string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var result []string
+	current := instNos
+	for len(current) > 0 {
+		var next []string
+		for _, no := range current {
+			if _, ok := seen[no]; ok {
+				continue
+			}
+			seen[no] = struct{}{}
+			result = append(result, no)
+			next = append(next, no)
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		var children []string
+		if err := DB.Model(&Branch{}).Where("parent_no IN ?", next).Pluck("inst_no", &children).Error; err != nil {
+			return nil, err
+		}
+		current = children
+	}
+	return result, nil
+}
+
+// AccessibleInstNos 获取用户可访问的网点编号
+// 拥有 branch.all 权限的总行角色可访问全部网点，此时 all 为 true
+func AccessibleInstNos(DB *gorm.DB, u *user.User) (instNos []string, all bool, err error) {
+	if user.HasPermission(DB, u, user.PermBranchAll) {
+		return nil, true, nil
+	}
+	instNos, err = Descendants(DB, SplitInstNos(u.InstNos))
+	return instNos, false, err
+}
+
+// Contains 判断网点编号是否在列表中
+func Contains(instNos []string, instNo string) bool {
+	for _, no := range instNos {
+		if no == instNo {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTree 获取指定上级网点下的网点树
+func GetTree(DB *gorm.DB, parentNo string) ([]BranchTree, error) {
+	var branches []Branch
+	if err := DB.Where("parent_no = ?", parentNo).Order("inst_no").Find(&branches).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]BranchTree, 0, len(branches))
+	for _, b := range branches {
+		children, err := GetTree(DB, b.InstNo)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, BranchTree{Branch: b, Children: children})
+	}
+	return result, nil
+}
+
+// CheckParent 校验上级网点存在且不会形成环
+func CheckParent(DB *gorm.DB, instNo, parentNo string) error {
+	if parentNo == "" {
+		return nil
+	}
+	if parentNo == instNo {
+		return errors.New("上级网点不能是自身")
+	}
+
+	var parent Branch
+	if err := DB.Where("inst_no = ?", parentNo).First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("上级网点不存在")
+		}
+		return err
+	}
+
+	descendants, err := Descendants(DB, []string{instNo})
+	if err != nil {
+		return err
+	}
+	if Contains(descendants, parentNo) {
+		return errors.New("上级网点不能是自身的下级网点")
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"liblink/internal/models/archive"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 位置层级，档案只能存放在档案盒中
+const (
+	LevelRoom    = "room"    // 库房
+	LevelCabinet = "cabinet" // 档案柜
+	LevelShelf   = "shelf"   // 层架
+	LevelBox     = "box"     // 档案盒
+)
+
+// Levels 由上到下的位置层级
+var Levels = []string{LevelRoom, LevelCabinet, LevelShelf, LevelBox}
+
+// Location 纸质档案的存放位置，按 库房 → 档案柜 → 层架 → 档案盒 逐级组成
+// Capacity 为可容纳的下级位置数，档案盒为可容纳的档案数，0 表示不限
+type Location struct {
+	gorm.Model
+	Code     string `gorm:"column:code;size:64;uniqueIndex;comment:'位置编码,用于标签与扫码'" json:"code"`
+	Name     string `gorm:"column:name;comment:'名称'" json:"name"`
+	Level    string `gorm:"column:level;size:16;index;comment:'层级'" json:"level"`
+	ParentID uint   `gorm:"column:parent_id;index;comment:'上级位置ID,库房为 0'" json:"parent_id"`
+	Capacity int    `gorm:"column:capacity;comment:'容量,0 为不限'" json:"capacity"`
+}
+
+// Movement 档案或位置的移动记录
+type Movement struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	TargetType string    `gorm:"column:target_type;size:16;index:idx_movement_target,priority:1;comment:'archive 或位置层级'" json:"target_type"`
+	TargetID   uint      `gorm:"column:target_id;index:idx_movement_target,priority:2;comment:'档案或位置ID'" json:"target_id"`
+	FromID     uint      `gorm:"column:from_id;comment:'原位置ID,首次放入为 0'" json:"from_id"`
+	ToID       uint      `gorm:"column:to_id;comment:'新位置ID'" json:"to_id"`
+	OperatorID string    `gorm:"column:operator_id;comment:'操作人'" json:"operator_id"`
+	Remark     string    `gorm:"column:remark;comment:'备注'" json:"remark"`
+}
+
+// TargetArchive 档案的移动记录类型，位置的移动记录使用位置层级
+const TargetArchive = "archive"
+
+// MoveError 移动校验失败，原因可直接返回给用户
+type MoveError struct {
+	Reason string
+}
+
+func (e *MoveError) Error() string {
+	return e.Reason
+}
+
+var ErrCapacity = &MoveError{"目标位置容量不足"}
+
+var codePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ParentLevel 上一级的层级，库房返回空
+func ParentLevel(level string) string {
+	for i, l := range Levels {
+		if l == level && i > 0 {
+			return Levels[i-1]
+		}
+	}
+	return ""
+}
+
+// IsLevel 是否为有效的层级
+func IsLevel(level string) bool {
+	for _, l := range Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 校验位置的编码、层级与上级位置
+func Check(DB *gorm.DB, l *Location) error {
+	l.Code = strings.TrimSpace(l.Code)
+	l.Name = strings.TrimSpace(l.Name)
+	if !codePattern.MatchString(l.Code) {
+		return errors.New("位置编码只能包含字母、数字、下划线与连字符，不超过 64 个字符")
+	}
+	if l.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if !IsLevel(l.Level) {
+		return errors.New("层级只能为 room、cabinet、shelf 或 box")
+	}
+	if l.Capacity < 0 {
+		return errors.New("容量不能为负数")
+	}
+
+	parentLevel := ParentLevel(l.Level)
+	if parentLevel == "" {
+		if l.ParentID != 0 {
+			return errors.New("库房不能有上级位置")
+		}
+		return nil
+	}
+	var parent Location
+	if err := DB.First(&parent, l.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("上级位置不存在")
+		}
+		return err
+	}
+	if parent.Level != parentLevel {
+		return fmt.Errorf("%s 的上级位置必须是 %s", l.Level, parentLevel)
+	}
+	return nil
+}
+
+// Used 已占用的容量，档案盒为存放的档案数，其余为下级位置数
+func Used(DB *gorm.DB, l *Location) (int64, error) {
+	var count int64
+	var err error
+	if l.Level == LevelBox {
+		err = DB.Model(&archive.Archive{}).Where("location_id = ?", l.ID).Count(&count).Error
+	} else {
+		err = DB.Model(&Location{}).Where("parent_id = ?", l.ID).Count(&count).Error
+	}
+	return count, err
+}
+
+// UsedMap 批量统计位置的占用数
+func UsedMap(DB *gorm.DB, locations []Location) (map[uint]int64, error) {
+	var boxes, others []uint
+	for _, l := range locations {
+		if l.Level == LevelBox {
+			boxes = append(boxes, l.ID)
+		} else {
+			others = append(others, l.ID)
+		}
+	}
+
+	type row struct {
+		ID    uint
+		Count int64
+	}
+	used := make(map[uint]int64, len(locations))
+	collect := func(db *gorm.DB, column string, ids []uint) error {
+		if len(ids) == 0 {
+			return nil
+		}
+		var rows []row
+		if err := db.Select(column+" AS id, COUNT(*) AS count").Where(column+" IN ?", ids).Group(column).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			used[r.ID] = r.Count
+		}
+		return nil
+	}
+	if err := collect(DB.Model(&archive.Archive{}), "location_id", boxes); err != nil {
+		return nil, err
+	}
+	if err := collect(DB.Model(&Location{}), "parent_id", others); err != nil {
+		return nil, err
+	}
+	return used, nil
+}
+
+// Paths 位置的完整路径，如 “一号库房 / A01 柜 / 第 3 层 / B015”，按位置ID返回
+func Paths(DB *gorm.DB, ids []uint) (map[uint]string, error) {
+	all := make(map[uint]Location)
+	pending := ids
+	for len(pending) > 0 {
+		var query []uint
+		for _, id := range pending {
+			if _, ok := all[id]; !ok && id != 0 {
+				query = append(query, id)
+			}
+		}
+		if len(query) == 0 {
+			break
+		}
+		var locations []Location
+		if err := DB.Where("id IN ?", query).Find(&locations).Error; err != nil {
+			return nil, err
+		}
+		pending = pending[:0]
+		for _, l := range locations {
+			all[l.ID] = l
+			pending = append(pending, l.ParentID)
+		}
+		if len(locations) == 0 {
+			break
+		}
+	}
+
+	paths := make(map[uint]string, len(ids))
+	for _, id := range ids {
+		var names []string
+		// 层级固定，最多向上查找 len(Levels) 次
+		for cur, i := id, 0; cur != 0 && i < len(Levels); i++ {
+			l, ok := all[cur]
+			if !ok {
+				break
+			}
+			names = append([]string{l.Name}, names...)
+			cur = l.ParentID
+		}
+		if len(names) > 0 {
+			paths[id] = strings.Join(names, " / ")
+		}
+	}
+	return paths, nil
+}
+
+// lockTarget 在事务中锁定目标位置并校验层级与剩余容量
+func lockTarget(tx *gorm.DB, id uint, level string, adding int64) (*Location, error) {
+	var to Location
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&to, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &MoveError{"目标位置不存在"}
+		}
+		return nil, err
+	}
+	if to.Level != level {
+		return nil, &MoveError{fmt.Sprintf("只能移动到 %s", level)}
+	}
+	if to.Capacity > 0 {
+		used, err := Used(tx, &to)
+		if err != nil {
+			return nil, err
+		}
+		if used+adding > int64(to.Capacity) {
+			return nil, ErrCapacity
+		}
+	}
+	return &to, nil
+}
+
+// MoveArchives 将档案放入档案盒并记录移动历史，已在该档案盒中的档案忽略
+func MoveArchives(DB *gorm.DB, archiveIDs []uint, toID uint, operator, remark string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var archives []archive.Archive
+		if err := tx.Select("id", "location_id").Where("id IN ? AND location_id <> ?", archiveIDs, toID).Find(&archives).Error; err != nil {
+			return err
+		}
+		if len(archives) == 0 {
+			return nil
+		}
+		if _, err := lockTarget(tx, toID, LevelBox, int64(len(archives))); err != nil {
+			return err
+		}
+
+		moves := make([]Movement, len(archives))
+		ids := make([]uint, len(archives))
+		for i, a := range archives {
+			ids[i] = a.ID
+			moves[i] = Movement{TargetType: TargetArchive, TargetID: a.ID, FromID: a.LocationID, ToID: toID, OperatorID: operator, Remark: remark}
+		}
+		if err := tx.Model(&archive.Archive{}).Where("id IN ?", ids).UpdateColumn("location_id", toID).Error; err != nil {
+			return err
+		}
+		return tx.Create(&moves).Error
+	})
+}
+
+// Move 将位置连同其中的档案移动到新的上级位置，如将档案盒移到另一个层架
+func Move(DB *gorm.DB, l *Location, toID uint, operator, remark string) error {
+	if l.ParentID == toID {
+		return nil
+	}
+	parentLevel := ParentLevel(l.Level)
+	if parentLevel == "" {
+		return &MoveError{"库房不能移动"}
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockTarget(tx, toID, parentLevel, 1); err != nil {
+			return err
+		}
+		from := l.ParentID
+		if err := tx.Model(l).UpdateColumn("parent_id", toID).Error; err != nil {
+			return err
+		}
+		l.ParentID = toID
+		return tx.Create(&Movement{TargetType: l.Level, TargetID: l.ID, FromID: from, ToID: toID, OperatorID: operator, Remark: remark}).Error
+	})
+}
@@ -0,0 +1,21 @@
+package location
+
+import "testing"
+
+func TestParentLevel(t *testing.T) {
+	cases := map[string]string{
+		LevelRoom:    "",
+		LevelCabinet: LevelRoom,
+		LevelShelf:   LevelCabinet,
+		LevelBox:     LevelShelf,
+		"drawer":     "",
+	}
+	for level, want := range cases {
+		if got := ParentLevel(level); got != want {
+			t.Errorf("ParentLevel(%q) = %q, want %q", level, got, want)
+		}
+	}
+	if IsLevel("drawer") || !IsLevel(LevelBox) {
+		t.Error("IsLevel() mismatch")
+	}
+}
@@ -1,12 +1,48 @@
 package system
 
 import (
+	"encoding/json"
+
 	"gorm.io/gorm"
 )
 
+// 通知类型
+const (
+	NotificationNotify = "Notify"
+	NotificationAlert  = "Alert"
+)
+
 type Notification struct {
 	gorm.Model
 	Type    string `gorm:"column:type;comment:'类型：通知(Notify)，警告(Alert)'" json:"type"`
 	Title   string `gorm:"column:title;comment:'标题'" json:"title"`
 	Content string `gorm:"column:content;comment:'正文'" json:"content"`
 }
+
+// AuditLog 审计日志，记录权限变更等敏感操作
+type AuditLog struct {
+	gorm.Model
+	OperatorID string `gorm:"column:operator_id;index;comment:'操作人'" json:"operator_id"`
+	Action     string `gorm:"column:action;size:64;index;comment:'操作类型'" json:"action"`
+	TargetType string `gorm:"column:target_type;size:32;comment:'操作对象类型'" json:"target_type"`
+	TargetID   string `gorm:"column:target_id;size:64;comment:'操作对象标识'" json:"target_id"`
+	Detail     string `gorm:"column:detail;type:text;comment:'操作详情,JSON'" json:"detail"`
+}
+
+// AddAuditLog 写入审计日志，detail 会被序列化为 JSON
+func AddAuditLog(DB *gorm.DB, operatorID, action, targetType, targetID string, detail interface{}) error {
+	log := AuditLog{
+		OperatorID: operatorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+	if detail != nil {
+		b, err := json.Marshal(detail)
+		if err != nil {
+			return err
+		}
+		log.Detail = string(b)
+	}
+	return DB.Create(&log).Error
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginLog 登录审计记录
+type LoginLog struct {
+	gorm.Model
+	UserID    uint   `gorm:"column:user_id;index;comment:'用户ID,账号不存在时为0'" json:"user_id"`
+	Email     string `gorm:"column:email;comment:'登录邮箱'" json:"email"`
+	IP        string `gorm:"column:ip;size:64;index:idx_ip_created;comment:'来源IP'" json:"ip"`
+	UserAgent string `gorm:"column:user_agent;comment:'User-Agent'" json:"user_agent"`
+	Success   bool   `gorm:"column:success;comment:'是否成功'" json:"success"`
+	Reason    string `gorm:"column:reason;comment:'失败原因'" json:"reason"`
+}
+
+// LoginDelay 连续失败后需要等待的时间，每次失败翻倍，不超过 max
+func LoginDelay(failures int, base, max time.Duration) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// IsLocked 账号是否处于锁定期
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// CountIPFailures 统计 IP 在指定时间后的失败次数
+func CountIPFailures(DB *gorm.DB, ip string, since time.Time) (int64, error) {
+	var count int64
+	err := DB.Model(&LoginLog{}).Where("ip = ? AND success = ? AND created_at > ?", ip, false, since).Count(&count).Error
+	return count, err
+}
+
+// RecordLoginFailure 记录一次账号登录失败，达到上限时锁定账号，返回是否被锁定
+func RecordLoginFailure(DB *gorm.DB, u *User, now time.Time, window time.Duration, maxFailures int, lock time.Duration) (bool, error) {
+	// 超出统计窗口则重新计数
+	if u.LastFailedAt == nil || now.Sub(*u.LastFailedAt) > window {
+		u.FailedLogins = 0
+	}
+	u.FailedLogins++
+	u.LastFailedAt = &now
+
+	updates := map[string]interface{}{
+		"failed_logins":  u.FailedLogins,
+		"last_failed_at": now,
+	}
+	locked := maxFailures > 0 && u.FailedLogins >= maxFailures
+	if locked {
+		until := now.Add(lock)
+		u.LockedUntil = &until
+		u.FailedLogins = 0
+		updates["locked_until"] = until
+		updates["failed_logins"] = 0
+	}
+	return locked, DB.Model(u).Updates(updates).Error
+}
+
+// ResetLoginFailures 清除失败计数与锁定状态
+func ResetLoginFailures(DB *gorm.DB, u *User) error {
+	u.FailedLogins = 0
+	u.LastFailedAt = nil
+	u.LockedUntil = nil
+	return DB.Model(u).Updates(map[string]interface{}{
+		"failed_logins":  0,
+		"last_failed_at": nil,
+		"locked_until":   nil,
+	}).Error
+}
@@ -0,0 +1,19 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestLoginDelay(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+
+	assert.Equal(t, time.Duration(0), LoginDelay(0, base, max))
+	assert.Equal(t, time.Second, LoginDelay(1, base, max))
+	assert.Equal(t, 2*time.Second, LoginDelay(2, base, max))
+	assert.Equal(t, 8*time.Second, LoginDelay(4, base, max))
+	assert.Equal(t, max, LoginDelay(5, base, max))
+	assert.Equal(t, max, LoginDelay(100, base, max))
+}
@@ -0,0 +1,237 @@
+package user
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 权限目录，角色即为若干权限的集合
+const (
+	PermArchiveRead      = "archive.read"        // 查看档案
+	PermArchiveWrite     = "archive.write"       // 新增、编辑、导入档案
+	PermArchiveHistory   = "archive.history"     // 查看档案借阅历史
+	PermArchiveSensitive = "archive.sensitive"   // 查看未脱敏的身份证号、姓名与金额
+	PermArchiveReveal    = "archive.reveal"      // 逐条查看档案敏感信息，记录审计日志
+	PermArchiveType      = "archive_type.manage" // 管理档案类型与自定义字段
+	PermLoanOperate      = "loan.operate"        // 借阅、归还档案
+	PermLoanApprove      = "loan.approve"        // 审批本网点的借阅申请
+	PermReportView       = "report.view"         // 查看统计报表
+	PermNotifyManage     = "notification.manage" // 发布系统通知
+	PermUserManage       = "user.manage"         // 管理用户
+	PermRoleManage       = "role.manage"         // 管理角色与权限
+	PermBranchAll        = "branch.all"          // 访问全部网点数据（总行）
+	PermBranchManage     = "branch.manage"       // 管理网点主数据及用户所属网点
+	PermAuditView        = "audit.view"          // 查看审计日志
+	PermLocationManage   = "location.manage"     // 管理存放位置、档案上架与移库
+)
+
+// 内置角色名称
+const (
+	RoleAdmin   = "admin"
+	RoleUser    = "user"
+	RoleClerk   = "clerk"
+	RoleAuditor = "auditor"
+	RoleManager = "manager"
+	RoleViewer  = "viewer"
+)
+
+type Permission struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// Permissions 系统支持的全部权限
+var Permissions = []Permission{
+	{PermArchiveRead, "查看档案"},
+	{PermArchiveWrite, "新增、编辑、导入档案"},
+	{PermArchiveHistory, "查看档案借阅历史"},
+	{PermArchiveSensitive, "查看未脱敏的身份证号、姓名与金额"},
+	{PermArchiveReveal, "逐条查看档案敏感信息（记录审计）"},
+	{PermArchiveType, "管理档案类型与自定义字段"},
+	{PermLoanOperate, "借阅、归还档案"},
+	{PermLoanApprove, "审批本网点的借阅申请"},
+	{PermReportView, "查看统计报表"},
+	{PermNotifyManage, "发布系统通知"},
+	{PermUserManage, "管理用户"},
+	{PermRoleManage, "管理角色与权限"},
+	{PermBranchAll, "访问全部网点数据（总行）"},
+	{PermBranchManage, "管理网点主数据及用户所属网点"},
+	{PermAuditView, "查看审计日志"},
+	{PermLocationManage, "管理存放位置、档案上架与移库"},
+}
+
+type Role struct {
+	gorm.Model
+	Name        string `gorm:"column:name;size:64;uniqueIndex;comment:'角色名称'" json:"name"`
+	Description string `gorm:"column:description;comment:'角色描述'" json:"description"`
+	Permissions string `gorm:"column:permissions;comment:'权限列表,逗号分隔'" json:"permissions"`
+	BuiltIn     bool   `gorm:"column:built_in;comment:'是否为内置角色'" json:"built_in"`
+	RequireTOTP bool   `gorm:"column:require_totp;comment:'是否强制两步验证'" json:"require_totp"`
+}
+
+// builtInRoles 内置角色及其默认权限，admin 始终拥有全部权限
+var builtInRoles = []Role{
+	{Name: RoleUser, Description: "普通用户", Permissions: joinPermissions(PermArchiveRead, PermArchiveWrite, PermLoanOperate, PermReportView)},
+	{Name: RoleClerk, Description: "档案管理员，负责导入与编辑", Permissions: joinPermissions(PermArchiveRead, PermArchiveWrite, PermLoanOperate, PermLocationManage)},
+	{Name: RoleAuditor, Description: "审计员，只读（含借阅历史）", Permissions: joinPermissions(PermArchiveRead, PermArchiveHistory, PermArchiveReveal, PermReportView, PermBranchAll, PermAuditView)},
+	{Name: RoleManager, Description: "网点负责人，审批本网点借阅", Permissions: joinPermissions(PermArchiveRead, PermArchiveHistory, PermArchiveReveal, PermLoanOperate, PermLoanApprove, PermReportView)},
+	{Name: RoleViewer, Description: "访客，仅可查看档案", Permissions: joinPermissions(PermArchiveRead)},
+}
+
+// Has 判断角色是否拥有指定权限
+func (r *Role) Has(perm string) bool {
+	for _, p := range strings.Split(r.Permissions, ",") {
+		if strings.TrimSpace(p) == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionList 角色的权限列表
+func (r *Role) PermissionList() []string {
+	var perms []string
+	for _, p := range strings.Split(r.Permissions, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// MissingPermission 返回 perms 中角色未拥有的第一个权限，全部拥有时返回空
+func (r *Role) MissingPermission(perms []string) string {
+	for _, p := range perms {
+		if p = strings.TrimSpace(p); p != "" && !r.Has(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// IsValidPermission 判断权限是否在权限目录中
+func IsValidPermission(code string) bool {
+	for _, p := range Permissions {
+		if p.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizePermissions 校验并拼接权限列表
+func NormalizePermissions(perms []string) (string, error) {
+	var valid []string
+	seen := make(map[string]struct{})
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !IsValidPermission(p) {
+			return "", errors.New("未知权限: " + p)
+		}
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		valid = append(valid, p)
+	}
+	return joinPermissions(valid...), nil
+}
+
+func joinPermissions(perms ...string) string {
+	return strings.Join(perms, ",")
+}
+
+func allPermissions() string {
+	codes := make([]string, 0, len(Permissions))
+	for _, p := range Permissions {
+		codes = append(codes, p.Code)
+	}
+	return joinPermissions(codes...)
+}
+
+// SeedRoles 初始化内置角色，已存在的角色不会被覆盖，admin 每次都会同步为全部权限
+func SeedRoles(DB *gorm.DB) error {
+	admin := Role{Name: RoleAdmin, Description: "系统管理员", BuiltIn: true}
+	if err := DB.Where(Role{Name: RoleAdmin}).Assign(Role{Permissions: allPermissions(), BuiltIn: true}).FirstOrCreate(&admin).Error; err != nil {
+		return err
+	}
+
+	for _, r := range builtInRoles {
+		r.BuiltIn = true
+		if err := DB.Where(Role{Name: r.Name}).FirstOrCreate(&r).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantPermissions 为已存在的内置角色追加权限，用于升级时补齐新增的默认权限
+// SeedRoles 不会修改已存在的角色，新增内置权限需要通过迁移调用本函数
+func GrantPermissions(DB *gorm.DB, roleName string, perms ...string) error {
+	var r Role
+	if err := DB.Where("name = ? AND built_in = ?", roleName, true).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	merged, err := NormalizePermissions(append(r.PermissionList(), perms...))
+	if err != nil {
+		return err
+	}
+	if merged == r.Permissions {
+		return nil
+	}
+	return DB.Model(&r).UpdateColumn("permissions", merged).Error
+}
+
+// RevokePermission 从全部角色中移除权限，用于下线权限目录中的权限
+func RevokePermission(DB *gorm.DB, perm string) error {
+	var roles []Role
+	if err := DB.Where("permissions LIKE ?", "%"+perm+"%").Find(&roles).Error; err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if !r.Has(perm) {
+			continue
+		}
+		var kept []string
+		for _, p := range r.PermissionList() {
+			if p != perm {
+				kept = append(kept, p)
+			}
+		}
+		if err := DB.Model(&r).UpdateColumn("permissions", joinPermissions(kept...)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRole 根据名称获取角色
+func GetRole(DB *gorm.DB, name string) (*Role, error) {
+	var r Role
+	if err := DB.Where("name = ?", name).First(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CanManage 判断 actor 能否管理 target 的账号，管理员账号只能由管理员修改、停用、删除或重置
+func CanManage(actor, target *User) bool {
+	return target.Role != RoleAdmin || actor.Role == RoleAdmin
+}
+
+// HasPermission 判断用户所属角色是否拥有指定权限
+func HasPermission(DB *gorm.DB, u *User, perm string) bool {
+	r, err := GetRole(DB, u.Role)
+	if err != nil {
+		return false
+	}
+	return r.Has(perm)
+}
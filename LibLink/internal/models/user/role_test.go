@@ -0,0 +1,39 @@
+package user
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestRoleHas(t *testing.T) {
+	r := Role{Permissions: joinPermissions(PermArchiveRead, PermLoanOperate)}
+	assert.Equal(t, true, r.Has(PermArchiveRead))
+	assert.Equal(t, true, r.Has(PermLoanOperate))
+	assert.Equal(t, false, r.Has(PermArchiveWrite))
+}
+
+func TestNormalizePermissions(t *testing.T) {
+	perms, err := NormalizePermissions([]string{" archive.read", "archive.read", "", "loan.operate"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "archive.read,loan.operate", perms)
+
+	_, err = NormalizePermissions([]string{"archive.destroy"})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRoleMissingPermission(t *testing.T) {
+	r := Role{Permissions: joinPermissions(PermArchiveRead, PermRoleManage)}
+	assert.Equal(t, "", r.MissingPermission([]string{PermArchiveRead, " ", PermRoleManage}))
+	assert.Equal(t, PermUserManage, r.MissingPermission([]string{PermArchiveRead, PermUserManage, PermBranchAll}))
+}
+
+func TestCanManage(t *testing.T) {
+	admin := &User{Role: RoleAdmin}
+	manager := &User{Role: RoleManager}
+	clerk := &User{Role: RoleClerk}
+	assert.Equal(t, true, CanManage(admin, admin))
+	assert.Equal(t, true, CanManage(admin, clerk))
+	assert.Equal(t, true, CanManage(manager, clerk))
+	assert.Equal(t, false, CanManage(manager, admin))
+}
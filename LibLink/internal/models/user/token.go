@@ -0,0 +1,105 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一次性令牌用途
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// UserToken 一次性令牌，仅保存哈希值
+type UserToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"column:user_id;index;comment:'用户ID'"`
+	Purpose   string     `gorm:"column:purpose;size:32;comment:'令牌用途'"`
+	TokenHash string     `gorm:"column:token_hash;size:64;uniqueIndex;comment:'令牌SHA-256'"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;comment:'过期时间'"`
+	UsedAt    *time.Time `gorm:"column:used_at;comment:'使用时间'"`
+}
+
+// Invite 管理员发放的注册邀请，角色与用户组由邀请决定
+type Invite struct {
+	gorm.Model
+	Email           string     `gorm:"column:email;comment:'受邀邮箱'" json:"email"`
+	Role            string     `gorm:"column:role;comment:'注册后的角色'" json:"role"`
+	PermissionGroup string     `gorm:"column:permission_group;comment:'注册后的用户组权限'" json:"permission_group"`
+	InstNos         string     `gorm:"column:inst_nos;comment:'注册后的所属网点'" json:"inst_nos"`
+	TokenHash       string     `gorm:"column:token_hash;size:64;uniqueIndex;comment:'邀请码SHA-256'" json:"-"`
+	CreatorID       string     `gorm:"column:creator_id;comment:'邀请人'" json:"creator_id"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;comment:'过期时间'" json:"expires_at"`
+	UsedAt          *time.Time `gorm:"column:used_at;comment:'使用时间'" json:"used_at"`
+}
+
+var ErrInvalidToken = errors.New("令牌无效或已过期")
+
+// NewToken 生成随机令牌及其哈希
+func NewToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken 计算令牌哈希
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueToken 为用户签发一次性令牌，同用途的旧令牌作废
+func IssueToken(DB *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).Delete(&UserToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	return token, err
+}
+
+// ConsumeToken 校验并使用一次性令牌
+func ConsumeToken(DB *gorm.DB, purpose, token string) (*UserToken, error) {
+	var t UserToken
+	if err := DB.Where("token_hash = ? AND purpose = ?", HashToken(token), purpose).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	// 条件更新，防止并发重复使用
+	now := time.Now()
+	result := DB.Model(&UserToken{}).Where("id = ? AND used_at IS NULL", t.ID).Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+	t.UsedAt = &now
+	return &t, nil
+}
@@ -0,0 +1,145 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"liblink/pkg/fieldcrypt"
+	"liblink/pkg/totp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// RecoveryCode 两步验证恢复码，仅保存哈希值，每个只能使用一次
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `gorm:"column:user_id;index;comment:'用户ID'"`
+	CodeHash string     `gorm:"column:code_hash;size:64;comment:'恢复码SHA-256'"`
+	UsedAt   *time.Time `gorm:"column:used_at;comment:'使用时间'"`
+}
+
+// RequireTOTP 用户是否必须通过两步验证登录
+func RequireTOTP(DB *gorm.DB, u *User) bool {
+	if u.TOTPEnabled {
+		return true
+	}
+	r, err := GetRole(DB, u.Role)
+	return err == nil && r.RequireTOTP
+}
+
+// VerifyTOTP 校验验证码，同一周期内的验证码只能使用一次
+func VerifyTOTP(DB *gorm.DB, u *User, code string) (bool, error) {
+	if u.TOTPSecret == "" {
+		return false, nil
+	}
+	counter, ok := totp.Validate(string(u.TOTPSecret), strings.TrimSpace(code), time.Now(), 1)
+	if !ok || counter <= u.TOTPLastCounter {
+		return false, nil
+	}
+
+	// 条件更新，防止同一验证码并发重放
+	result := DB.Model(&User{}).Where("id = ? AND totp_last_counter < ?", u.ID, counter).Update("totp_last_counter", counter)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	u.TOTPLastCounter = counter
+	return result.RowsAffected > 0, nil
+}
+
+// GenerateRecoveryCodes 生成新的恢复码，旧恢复码全部作废
+func GenerateRecoveryCodes(DB *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		records = append(records, RecoveryCode{UserID: userID, CodeHash: HashToken(code)})
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	return codes, err
+}
+
+// UseRecoveryCode 使用一个恢复码
+func UseRecoveryCode(DB *gorm.DB, userID uint, code string) (bool, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	result := DB.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(code)).
+		Limit(1).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// EnableTOTP 启用两步验证
+func EnableTOTP(DB *gorm.DB, u *User) error {
+	u.TOTPEnabled = true
+	return DB.Model(u).Update("totp_enabled", true).Error
+}
+
+// DisableTOTP 关闭两步验证并清除密钥与恢复码
+func DisableTOTP(DB *gorm.DB, u *User) error {
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastCounter = 0
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(u).Updates(map[string]interface{}{
+			"totp_enabled":      false,
+			"totp_secret":       "",
+			"totp_last_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", u.ID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// EncryptTOTPSecrets 加密历史明文的两步验证密钥，或用主密钥重新加密，可重复执行，返回更新的用户数
+func EncryptTOTPSecrets(DB *gorm.DB) (int, error) {
+	keyring := fieldcrypt.Default()
+	if keyring == nil {
+		return 0, fieldcrypt.ErrNoKeyring
+	}
+
+	type secretRow struct {
+		ID         uint
+		TOTPSecret string
+	}
+	// 以原始字符串读取，避免自动解密
+	var rows []secretRow
+	if err := DB.Table("users").Select("id, totp_secret").Where("totp_secret <> ''").Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	var updated int
+	for _, row := range rows {
+		if keyring.IsCurrent(row.TOTPSecret) {
+			continue
+		}
+		secret, err := keyring.Decrypt(row.TOTPSecret)
+		if err != nil {
+			return updated, fmt.Errorf("user %d: %w", row.ID, err)
+		}
+		if err := DB.Table("users").Where("id = ?", row.ID).UpdateColumn("totp_secret", fieldcrypt.String(secret)).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
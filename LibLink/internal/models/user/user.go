@@ -1,16 +1,79 @@
 package user
 
 import (
+	"liblink/pkg/fieldcrypt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// 用户状态
+const (
+	StatusActive     = "active"     // 正常
+	StatusUnverified = "unverified" // 邮箱未验证
+	StatusDisabled   = "disabled"   // 已停用
+)
+
+// 用户来源
+const (
+	SourceLocal = "local" // 本地账号，密码保存在本系统
+	SourceLDAP  = "ldap"  // 目录账号，密码由 LDAP 校验
+	SourceOIDC  = "oidc"  // 单点登录账号，由 OIDC 提供方认证
+)
+
 type User struct {
 	gorm.Model
-	Username        string `gorm:"column:username;comment:'用户名'"`
-	Password        string `gorm:"column:password;comment:'密码'"`
-	Email           string `gorm:"column:email;comment:'email/唯一标识符'"`
-	Role            string `gorm:"column:role;comment:'角色,admin与user';default:user"`
-	PermissionGroup string `gorm:"column:permission_group;comment:'用户组权限,逗号分隔'"`
+	Username        string            `gorm:"column:username;comment:'用户名'"`
+	Password        string            `gorm:"column:password;comment:'密码'"`
+	Email           string            `gorm:"column:email;comment:'email/唯一标识符'"`
+	Role            string            `gorm:"column:role;comment:'角色名称,对应 roles 表';default:user"`
+	PermissionGroup string            `gorm:"column:permission_group;comment:'用户组权限,逗号分隔'"`
+	InstNos         string            `gorm:"column:inst_nos;comment:'所属网点编号,逗号分隔,包含其下级网点'"`
+	Status          string            `gorm:"column:status;size:16;comment:'用户状态';default:active"`
+	FailedLogins    int               `gorm:"column:failed_logins;comment:'连续登录失败次数'"`
+	LastFailedAt    *time.Time        `gorm:"column:last_failed_at;comment:'最近一次登录失败时间'"`
+	LockedUntil     *time.Time        `gorm:"column:locked_until;comment:'锁定截止时间'"`
+	TokenVersion    uint              `gorm:"column:token_version;comment:'令牌版本,递增后已签发的登录令牌全部失效'"`
+	MustChangePwd   bool              `gorm:"column:must_change_pwd;comment:'下次登录需修改密码'"`
+	TOTPSecret      fieldcrypt.String `gorm:"column:totp_secret;comment:'两步验证密钥,加密存储'"`
+	TOTPEnabled     bool              `gorm:"column:totp_enabled;comment:'是否已启用两步验证'"`
+	TOTPLastCounter uint64            `gorm:"column:totp_last_counter;comment:'最近一次使用的验证码周期,防止重放'"`
+	Source          string            `gorm:"column:source;size:16;comment:'账号来源';default:local"`
+	ExternalID      string            `gorm:"column:external_id;comment:'外部账号标识,如 LDAP DN'"`
+}
+
+// Profile 对外展示的用户信息，不包含密码等敏感字段
+type Profile struct {
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	PermissionGroup string     `json:"permission_group"`
+	InstNos         string     `json:"inst_nos"`
+	Status          string     `json:"status"`
+	LockedUntil     *time.Time `json:"locked_until"`
+	MustChangePwd   bool       `json:"must_change_password"`
+	TOTPEnabled     bool       `json:"totp_enabled"`
+	Source          string     `json:"source"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (u *User) Profile() Profile {
+	return Profile{
+		ID:              u.ID,
+		Username:        u.Username,
+		Email:           u.Email,
+		Role:            u.Role,
+		PermissionGroup: u.PermissionGroup,
+		InstNos:         u.InstNos,
+		Status:          u.Status,
+		LockedUntil:     u.LockedUntil,
+		MustChangePwd:   u.MustChangePwd,
+		TOTPEnabled:     u.TOTPEnabled,
+		Source:          u.Source,
+		CreatedAt:       u.CreatedAt,
+	}
 }
 
 type UserGroup struct {
@@ -18,3 +81,51 @@ type UserGroup struct {
 	Name        string `gorm:"column:name;comment:'用户组名称'"`
 	Description string `gorm:"column:description;comment:'用户组描述'"`
 }
+
+// HashPassword 生成密码的 bcrypt 结果
+func HashPassword(pwd string, cost int) (string, error) {
+	encrypt, err := bcrypt.GenerateFromPassword([]byte(pwd), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(encrypt), nil
+}
+
+// SetPassword 设置新密码并使已签发的登录令牌失效
+func SetPassword(DB *gorm.DB, u *User, hash string, mustChange bool) error {
+	u.Password = hash
+	u.TokenVersion++
+	u.MustChangePwd = mustChange
+	return DB.Model(u).Updates(map[string]interface{}{
+		"password":        u.Password,
+		"token_version":   u.TokenVersion,
+		"must_change_pwd": u.MustChangePwd,
+	}).Error
+}
+
+// SeedAdmin 用户表为空时创建初始管理员
+func SeedAdmin(DB *gorm.DB, email, pwd string, cost int) error {
+	if email == "" || pwd == "" {
+		return nil
+	}
+
+	var count int64
+	if err := DB.Model(&User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	hash, err := HashPassword(pwd, cost)
+	if err != nil {
+		return err
+	}
+	return DB.Create(&User{
+		Username: "admin",
+		Email:    email,
+		Password: hash,
+		Role:     RoleAdmin,
+		Status:   StatusActive,
+	}).Error
+}
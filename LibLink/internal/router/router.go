@@ -3,6 +3,7 @@ package router
 import (
 	"liblink/internal/controllers/api"
 	"liblink/internal/middleware"
+	"liblink/internal/models/user"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +14,15 @@ func Router() *gin.Engine {
 	router.Use(middleware.CORS())
 	router.POST("/register", api.Register)
 	router.POST("/login", api.Login)
+	router.POST("/login/2fa", api.LoginTwoFactor)
+	router.POST("/login/2fa/setup", api.LoginTwoFactorSetup)
+	router.POST("/login/2fa/activate", api.LoginTwoFactorActivate)
+	router.GET("/auth/oidc/login", api.OIDCLogin)
+	router.GET("/auth/oidc/callback", api.OIDCCallback)
+	router.GET("/verify_email", api.VerifyEmail)
+	router.POST("/verify_email/resend", api.ResendVerifyEmail)
+	router.POST("/forgot_password", api.ForgotPassword)
+	router.POST("/reset_password", api.ResetPassword)
 	router.GET("/ping_without_login", api.Ping)
 
 	router.Static("/static", "./static")
@@ -23,7 +33,51 @@ func Router() *gin.Engine {
 		// 用户相关
 		users := authRoutes.Group("/users")
 		{
-			users.GET("/summary", api.UsersSummary)
+			users.GET("/summary", middleware.RequirePermission(user.PermReportView), api.UsersSummary)
+			users.GET("/me", api.MyProfile)
+			users.PUT("/me", api.UpdateMyProfile)
+			users.GET("/list", middleware.RequirePermission(user.PermUserManage), api.Users)
+			users.GET("/detail/:id", middleware.RequirePermission(user.PermUserManage), api.UserDetail)
+			users.PUT("/update/:id", middleware.RequirePermission(user.PermUserManage), api.UpdateUser)
+			users.PATCH("/disable/:id", middleware.RequirePermission(user.PermUserManage), api.DisableUser)
+			users.PATCH("/enable/:id", middleware.RequirePermission(user.PermUserManage), api.EnableUser)
+			users.DELETE("/delete/:id", middleware.RequirePermission(user.PermUserManage), api.DeleteUser)
+			users.GET("/me/logins", api.MyLogins)
+			users.PUT("/me/password", api.ChangePassword)
+			users.POST("/me/totp/setup", api.TOTPSetup)
+			users.POST("/me/totp/activate", api.TOTPActivate)
+			users.POST("/me/totp/disable", api.TOTPDisable)
+			users.POST("/me/totp/recovery_codes", api.TOTPRecoveryCodes)
+			users.PATCH("/totp/reset/:id", middleware.RequirePermission(user.PermUserManage), api.ResetUserTOTP)
+			users.PUT("/password/reset", middleware.RequirePermission(user.PermUserManage), api.AdminResetPassword)
+			users.PATCH("/unlock", middleware.RequirePermission(user.PermUserManage), api.UnlockUser)
+			invites := users.Group("/invites")
+			invites.Use(middleware.RequirePermission(user.PermUserManage))
+			{
+				invites.GET("/list", api.Invites)
+				invites.POST("/add", api.AddInvite)
+				invites.DELETE("/delete/:id", api.DeleteInvite)
+			}
+		}
+		// 角色与权限
+		roles := authRoutes.Group("/roles")
+		roles.Use(middleware.RequirePermission(user.PermRoleManage))
+		{
+			roles.GET("/list", api.Roles)
+			roles.GET("/permissions", api.Permissions)
+			roles.POST("/add", api.AddRole)
+			roles.PUT("/update/:id", api.UpdateRole)
+			roles.DELETE("/delete/:id", api.DeleteRole)
+			roles.PATCH("/assign", api.AssignRole)
+		}
+		// 网点相关
+		branches := authRoutes.Group("/branches")
+		{
+			branches.GET("/list", api.Branches)
+			branches.POST("/add", middleware.RequirePermission(user.PermBranchManage), api.AddBranch)
+			branches.PUT("/update/:inst_no", middleware.RequirePermission(user.PermBranchManage), api.UpdateBranch)
+			branches.DELETE("/delete/:inst_no", middleware.RequirePermission(user.PermBranchManage), api.DeleteBranch)
+			branches.PUT("/assign", middleware.RequirePermission(user.PermBranchManage), api.AssignBranches)
 		}
 		// 系统相关
 		system := authRoutes.Group("/system")
@@ -31,18 +85,79 @@ func Router() *gin.Engine {
 			notification := system.Group("/notifications")
 			{
 				notification.GET("/list", api.Notifications)
-				notification.POST("/add", api.AddNotification)
+				notification.POST("/add", middleware.RequirePermission(user.PermNotifyManage), api.AddNotification)
 			}
+			system.GET("/audit_logs", middleware.RequirePermission(user.PermAuditView), api.AuditLogs)
+		}
+		// 保存的查询与订阅
+		savedSearches := authRoutes.Group("/saved_searches")
+		{
+			savedSearches.GET("/list", middleware.RequirePermission(user.PermArchiveRead), api.SavedSearches)
+			savedSearches.POST("/add", middleware.RequirePermission(user.PermArchiveRead), api.AddSavedSearch)
+			savedSearches.PUT("/update/:id", middleware.RequirePermission(user.PermArchiveRead), api.UpdateSavedSearch)
+			savedSearches.DELETE("/delete/:id", middleware.RequirePermission(user.PermArchiveRead), api.DeleteSavedSearch)
+			savedSearches.GET("/run/:id", middleware.RequirePermission(user.PermArchiveRead), api.RunSavedSearch)
+			savedSearches.POST("/subscribe/:id", middleware.RequirePermission(user.PermArchiveRead), api.SubscribeSavedSearch)
+			savedSearches.DELETE("/unsubscribe/:id", middleware.RequirePermission(user.PermArchiveRead), api.UnsubscribeSavedSearch)
+		}
+		// 纸质档案存放位置
+		locations := authRoutes.Group("/locations")
+		{
+			locations.GET("/list", middleware.RequirePermission(user.PermArchiveRead), api.Locations)
+			locations.POST("/add", middleware.RequirePermission(user.PermLocationManage), api.AddLocation)
+			locations.PUT("/update/:id", middleware.RequirePermission(user.PermLocationManage), api.UpdateLocation)
+			locations.DELETE("/delete/:id", middleware.RequirePermission(user.PermLocationManage), api.DeleteLocation)
+			locations.PATCH("/move/:id", middleware.RequirePermission(user.PermLocationManage), api.MoveLocation)
+			locations.PATCH("/move_archives", middleware.RequirePermission(user.PermLocationManage), api.MoveArchives)
+			locations.GET("/movements", middleware.RequirePermission(user.PermArchiveRead), api.Movements)
+		}
+		// 条形码、二维码标签与扫码查找
+		labels := authRoutes.Group("/labels")
+		{
+			labels.GET("/archive/:id", middleware.RequirePermission(user.PermArchiveRead), api.ArchiveBarcode)
+			labels.GET("/location/:id", middleware.RequirePermission(user.PermArchiveRead), api.LocationBarcode)
+			labels.POST("/sheet", middleware.RequirePermission(user.PermArchiveRead), api.LabelSheet)
+			labels.GET("/lookup", middleware.RequirePermission(user.PermArchiveRead), api.LookupCode)
+		}
+		// 档案类型与自定义字段
+		archiveTypes := authRoutes.Group("/archive_types")
+		{
+			archiveTypes.GET("/list", middleware.RequirePermission(user.PermArchiveRead), api.ArchiveTypes)
+			archiveTypes.POST("/add", middleware.RequirePermission(user.PermArchiveType), api.AddArchiveType)
+			archiveTypes.PUT("/update/:code", middleware.RequirePermission(user.PermArchiveType), api.UpdateArchiveType)
+			archiveTypes.DELETE("/delete/:code", middleware.RequirePermission(user.PermArchiveType), api.DeleteArchiveType)
 		}
 		archives := authRoutes.Group("/archives")
 		{
-			archives.GET("/list", api.GetArchives)
-			archives.POST("/add", api.AddArchive)
-			archives.PATCH("/borrow", api.BorrowArchive)
-			archives.PATCH("/return", api.ReturnArchive)
-			archives.PUT("/update/:id", api.UpdateArchive)
-			archives.POST("/batch_import", api.BatchImportArchives)
-			archives.POST("/batch_operate", api.BatchOperateArchives)
+			archives.GET("/list", middleware.RequirePermission(user.PermArchiveRead), api.GetArchives)
+			archives.GET("/search", middleware.RequirePermission(user.PermArchiveRead), api.SearchArchives)
+			archives.GET("/detail", middleware.RequirePermission(user.PermArchiveRead), api.GetArchiveByID)
+			archives.POST("/reveal/:id", middleware.RequirePermission(user.PermArchiveReveal), api.RevealArchive)
+			archives.GET("/summary", middleware.RequirePermission(user.PermReportView), api.ArchivesSummary)
+			archives.GET("/records", middleware.RequirePermission(user.PermArchiveHistory), api.ArchiveRecords)
+			archives.POST("/add", middleware.RequirePermission(user.PermArchiveWrite), api.AddArchive)
+			archives.PATCH("/borrow", middleware.RequirePermission(user.PermLoanOperate), api.BorrowArchive)
+			archives.PATCH("/return", middleware.RequirePermission(user.PermLoanOperate), api.ReturnArchive)
+			archives.PUT("/update/:id", middleware.RequirePermission(user.PermArchiveWrite), api.UpdateArchive)
+			archives.GET("/legacy_values", middleware.RequirePermission(user.PermArchiveWrite), api.LegacyValues)
+			archives.POST("/batch_import", middleware.RequirePermission(user.PermArchiveWrite), api.BatchImportArchives)
+			archives.POST("/batch_operate", middleware.RequirePermission(user.PermLoanOperate), api.BatchOperateArchives)
+			// 柜台扫码借还
+			archives.POST("/desk", middleware.RequirePermission(user.PermLoanOperate), api.DeskOperate)
+			archives.GET("/loans", middleware.RequirePermission(user.PermArchiveHistory), api.Loans)
+			// 借阅申请与审批
+			archives.POST("/loans/request", middleware.RequirePermission(user.PermArchiveRead), api.RequestLoan)
+			archives.POST("/loans/approve/:id", middleware.RequirePermission(user.PermLoanApprove), api.ApproveLoan)
+			archives.POST("/loans/reject/:id", middleware.RequirePermission(user.PermLoanApprove), api.RejectLoan)
+			// 电子扫描件
+			archives.GET("/attachments/list/:id", middleware.RequirePermission(user.PermArchiveRead), api.Attachments)
+			archives.POST("/attachments/upload/:id", middleware.RequirePermission(user.PermArchiveWrite), api.UploadAttachment)
+			archives.GET("/attachments/download/:attachment_id", middleware.RequirePermission(user.PermArchiveRead), api.DownloadAttachment)
+			archives.DELETE("/attachments/delete/:attachment_id", middleware.RequirePermission(user.PermArchiveWrite), api.DeleteAttachment)
+			archives.GET("/attachments/versions/list/:attachment_id", middleware.RequirePermission(user.PermArchiveRead), api.AttachmentVersions)
+			archives.POST("/attachments/versions/upload/:attachment_id", middleware.RequirePermission(user.PermArchiveWrite), api.UploadAttachmentVersion)
+			archives.GET("/attachments/text/:attachment_id", middleware.RequirePermission(user.PermArchiveRead), api.AttachmentText)
+			archives.POST("/attachments/ocr/:attachment_id", middleware.RequirePermission(user.PermArchiveWrite), api.RecognizeAttachment)
 		}
 	}
 
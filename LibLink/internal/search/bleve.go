@@ -0,0 +1,281 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"liblink/internal/models/archive"
+	"liblink/pkg/fieldcrypt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"gorm.io/gorm"
+)
+
+// MaxHits Bleve 单次检索返回的档案数上限，超出部分不会出现在结果中
+const MaxHits = 10000
+
+// Bleve 嵌入式全文索引，无需数据库支持 ngram，英文支持拼写容错
+// 档案新增、修改、删除时通过 gorm 回调同步索引，按条件批量更新不会触发同步，需执行 search-reindex
+type Bleve struct {
+	index   bleve.Index
+	Created bool        // 索引为新建，需要重建
+	OnError func(error) // 同步索引失败时调用，不影响数据库操作
+}
+
+// bleveDoc 写入索引的档案字段
+type bleveDoc struct {
+	Title      string `json:"title"`
+	Name       string `json:"name,omitempty"`
+	ContractNo string `json:"contract_no"`
+	Manager    string `json:"manager"`
+	FileNo     string `json:"file_no"`
+}
+
+// OpenBleve 打开索引目录，不存在时新建
+func OpenBleve(path string) (*Bleve, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			return nil, err
+		}
+		index, err = bleve.New(path, indexMapping())
+		return &Bleve{index: index, Created: true}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Bleve{index: index}, nil
+}
+
+// NewMemBleve 内存中的索引，用于测试
+func NewMemBleve() (*Bleve, error) {
+	index, err := bleve.NewMemOnly(indexMapping())
+	if err != nil {
+		return nil, err
+	}
+	return &Bleve{index: index, Created: true}, nil
+}
+
+// indexMapping 中文按双字切分，不保存原文
+func indexMapping() *mapping.IndexMappingImpl {
+	m := bleve.NewIndexMapping()
+	m.DefaultAnalyzer = cjk.AnalyzerName
+	doc := bleve.NewDocumentStaticMapping()
+	for _, field := range Fields {
+		f := bleve.NewTextFieldMapping()
+		f.Store = false
+		doc.AddFieldMappingsAt(field, f)
+	}
+	m.DefaultMapping = doc
+	return m
+}
+
+func (b *Bleve) Close() error {
+	return b.index.Close()
+}
+
+// Put 写入或更新档案
+func (b *Bleve) Put(a *archive.Archive) error {
+	return b.index.Index(strconv.FormatUint(uint64(a.ID), 10), toDoc(a))
+}
+
+// Delete 从索引中删除档案
+func (b *Bleve) Delete(id uint) error {
+	return b.index.Delete(strconv.FormatUint(uint64(id), 10))
+}
+
+func toDoc(a *archive.Archive) bleveDoc {
+	doc := bleveDoc{
+		Title:      a.Title,
+		ContractNo: a.ContractNo,
+		Manager:    a.Manager,
+		FileNo:     a.FileNo,
+	}
+	// 启用加密时不将明文姓名写入索引文件
+	if fieldcrypt.Default() == nil {
+		doc.Name = string(a.Name)
+	}
+	return doc
+}
+
+func (b *Bleve) Scope(ctx context.Context, q string) (func(db *gorm.DB) *gorm.DB, error) {
+	if len(archive.SearchTerms(q)) == 0 {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+	ids, err := b.Match(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		if len(ids) == 0 {
+			return db.Where("1 = 0")
+		}
+		return db.Where("id IN ?", ids)
+	}, nil
+}
+
+// Match 返回匹配检索词的档案ID，按ID升序，最多 MaxHits 个
+// 每个检索词都必须匹配，长度不少于 4 的英文词允许 1 个字符的差异，含数字的编号类检索词精确匹配
+func (b *Bleve) Match(ctx context.Context, q string) ([]uint, error) {
+	terms := archive.SearchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	queries := make([]query.Query, 0, len(terms))
+	for _, term := range terms {
+		m := bleve.NewMatchQuery(term)
+		m.SetOperator(query.MatchQueryOperatorAnd)
+		if utf8.RuneCountInString(term) >= 4 && isLetters(term) {
+			m.SetFuzziness(1)
+		}
+		queries = append(queries, m)
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(queries...), MaxHits, 0, false)
+	res, err := b.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for _, hit := range res.Hits {
+		if id, err := strconv.ParseUint(hit.ID, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rebuild 按ID分批写入全部档案，并删除已不存在的档案
+func (b *Bleve) Rebuild(ctx context.Context, DB *gorm.DB) (int, error) {
+	var count int
+	var lastID uint
+	for {
+		var archives []archive.Archive
+		if err := DB.WithContext(ctx).Where("id > ?", lastID).Order("id").Limit(500).Find(&archives).Error; err != nil {
+			return count, err
+		}
+		if len(archives) == 0 {
+			break
+		}
+		batch := b.index.NewBatch()
+		for i := range archives {
+			if err := batch.Index(strconv.FormatUint(uint64(archives[i].ID), 10), toDoc(&archives[i])); err != nil {
+				return count, err
+			}
+			lastID = archives[i].ID
+		}
+		if err := b.index.Batch(batch); err != nil {
+			return count, err
+		}
+		count += len(archives)
+	}
+
+	// 清理索引中已删除的档案
+	var deleted []uint
+	if err := DB.WithContext(ctx).Unscoped().Model(&archive.Archive{}).Where("deleted_at IS NOT NULL").Pluck("id", &deleted).Error; err != nil {
+		return count, err
+	}
+	batch := b.index.NewBatch()
+	for _, id := range deleted {
+		batch.Delete(strconv.FormatUint(uint64(id), 10))
+	}
+	return count, b.index.Batch(batch)
+}
+
+// Register 注册 gorm 回调，档案写入数据库后同步索引
+func (b *Bleve) Register(DB *gorm.DB) error {
+	if err := DB.Callback().Create().After("gorm:create").Register("search:create", b.sync); err != nil {
+		return err
+	}
+	if err := DB.Callback().Update().After("gorm:update").Register("search:update", b.sync); err != nil {
+		return err
+	}
+	return DB.Callback().Delete().After("gorm:delete").Register("search:delete", b.sync)
+}
+
+// sync 重新读取语句涉及的档案，已删除的从索引中移除
+func (b *Bleve) sync(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.Table != "archives" {
+		return
+	}
+	ids := archiveIDs(db.Statement.ReflectValue)
+	if len(ids) == 0 {
+		return
+	}
+
+	var archives []archive.Archive
+	if err := db.Session(&gorm.Session{NewDB: true}).Unscoped().Where("id IN ?", ids).Find(&archives).Error; err != nil {
+		b.fail(err)
+		return
+	}
+	found := make(map[uint]bool, len(archives))
+	for i := range archives {
+		found[archives[i].ID] = true
+		var err error
+		if archives[i].DeletedAt.Valid {
+			err = b.Delete(archives[i].ID)
+		} else {
+			err = b.Put(&archives[i])
+		}
+		if err != nil {
+			b.fail(err)
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			if err := b.Delete(id); err != nil {
+				b.fail(err)
+			}
+		}
+	}
+}
+
+func (b *Bleve) fail(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
+
+// archiveIDs 取出语句模型中的档案ID，支持单个档案与档案切片
+func archiveIDs(v reflect.Value) []uint {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	var ids []uint
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(archive.Archive{}) {
+			if id := uint(v.FieldByName("ID").Uint()); id != 0 {
+				ids = append(ids, id)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			ids = append(ids, archiveIDs(v.Index(i))...)
+		}
+	}
+	return ids
+}
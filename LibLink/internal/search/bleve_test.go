@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"liblink/internal/models/archive"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestBleveScope(t *testing.T) {
+	b, err := NewMemBleve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	archives := []archive.Archive{
+		{Model: gorm.Model{ID: 1}, Title: "个人住房贷款合同", ContractNo: "HT2024001", Manager: "李四", Name: "张三", FileNo: "LOAN-2024-0001"},
+		{Model: gorm.Model{ID: 2}, Title: "个人消费贷款合同", ContractNo: "HT2024002", Manager: "Wang Wu", FileNo: "LOAN-2024-0002"},
+		{Model: gorm.Model{ID: 3}, Title: "抵押登记证明", ContractNo: "DY2024001", Manager: "李四", FileNo: "MORT-2024-0001"},
+	}
+	for i := range archives {
+		if err := b.Put(&archives[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string][]uint{
+		"贷款合同":         {1, 2},
+		"住房 贷款":        {1},
+		"李四":           {1, 3},
+		"张三":           {1},
+		"ht2024002":    {2},
+		"HT2024003":    nil, // 编号不做拼写容错
+		"wamg":         {2}, // 拼写容错
+		"loan-2024":    {1, 2},
+		"汽车":           nil,
+		"李四 抵押登记":      {3},
+		"\"李四\" +抵押登记": {3},
+	}
+	for q, want := range cases {
+		got, err := b.Match(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Match(%q) = %v", q, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Match(%q) = %v, want %v", q, got, want)
+		}
+	}
+
+	if err := b.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Match(context.Background(), "张三"); got != nil {
+		t.Errorf("Match() after delete = %v", got)
+	}
+}
+
+func TestArchiveIDs(t *testing.T) {
+	one := &archive.Archive{Model: gorm.Model{ID: 7}}
+	many := []archive.Archive{{Model: gorm.Model{ID: 1}}, {}, {Model: gorm.Model{ID: 3}}}
+	ptrs := []*archive.Archive{{Model: gorm.Model{ID: 4}}, nil}
+
+	got := append(archiveIDs(reflect.ValueOf(one)), archiveIDs(reflect.ValueOf(&many))...)
+	got = append(got, archiveIDs(reflect.ValueOf(&ptrs))...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if want := []uint{1, 3, 4, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("archiveIDs() = %v, want %v", got, want)
+	}
+	if ids := archiveIDs(reflect.ValueOf(&archive.ArchiveType{})); ids != nil {
+		t.Errorf("archiveIDs() other model = %v", ids)
+	}
+}
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"context"
+	"liblink/internal/models/archive"
+	"liblink/pkg/fieldcrypt"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// ngramTokenSize MySQL ngram 分词的默认长度，更短的词无法使用全文索引
+const ngramTokenSize = 2
+
+// MySQL 使用 archives 表上的 ngram 全文索引检索，索引由数据库维护
+// 每个检索词都必须在任一字段中出现，单字检索退化为 LIKE 匹配
+type MySQL struct{}
+
+func (MySQL) Scope(ctx context.Context, q string) (func(db *gorm.DB) *gorm.DB, error) {
+	terms := archive.SearchTerms(q)
+	// 姓名加密后只能在应用中解密，不参与检索
+	searchName := fieldcrypt.Default() == nil
+
+	return func(db *gorm.DB) *gorm.DB {
+		for _, term := range terms {
+			like := "%" + term + "%"
+			nameCond, nameArgs := "", []interface{}{}
+			if searchName {
+				nameCond, nameArgs = " OR name LIKE ?", []interface{}{like}
+			}
+
+			if utf8.RuneCountInString(term) < ngramTokenSize {
+				db = db.Where("(title LIKE ? OR contract_no LIKE ? OR manager LIKE ? OR file_no LIKE ?"+nameCond+")",
+					append([]interface{}{like, like, like, like}, nameArgs...)...)
+				continue
+			}
+			db = db.Where("(MATCH(title, contract_no, manager, file_no) AGAINST (? IN BOOLEAN MODE)"+nameCond+")",
+				append([]interface{}{`"` + strings.ReplaceAll(term, `"`, "") + `"`}, nameArgs...)...)
+		}
+		return db
+	}, nil
+}
+
+func (MySQL) Rebuild(ctx context.Context, DB *gorm.DB) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,20 @@
+// Package search 档案检索索引
+// 检索词只用于确定匹配的档案，权限、筛选、排序与分页仍由数据库查询完成
+package search
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Fields 参与检索的档案字段，姓名加密存储时不参与检索
+var Fields = []string{"title", "name", "contract_no", "manager", "file_no"}
+
+// Index 档案检索索引接口，便于替换为其他实现
+type Index interface {
+	// Scope 返回匹配检索词的档案筛选条件
+	Scope(ctx context.Context, q string) (func(db *gorm.DB) *gorm.DB, error)
+	// Rebuild 重建索引，返回写入的档案数，由数据库维护索引的实现无需重建
+	Rebuild(ctx context.Context, DB *gorm.DB) (int, error)
+}
@@ -0,0 +1,196 @@
+// Package fieldcrypt 敏感字段加密存储，AES-256-GCM 加密并提供 HMAC 盲索引用于等值查询
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 密文格式：enc:<密钥ID>:<base64(nonce+密文)>，不带前缀的值视为尚未加密的历史明文
+const prefix = "enc:"
+
+var (
+	ErrNoKeyring  = errors.New("未配置字段加密密钥")
+	ErrUnknownKey = errors.New("未知的字段加密密钥")
+	ErrCiphertext = errors.New("密文格式错误")
+)
+
+// Keyring 加密密钥环，新数据使用主密钥加密，旧密钥仅用于解密，便于轮换
+type Keyring struct {
+	primary  string
+	aeads    map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring keys 为密钥ID到 32 字节密钥的映射，indexKey 用于盲索引，轮换数据密钥时不可更换
+func NewKeyring(primary string, keys map[string][]byte, indexKey []byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("主密钥 %q 不存在", primary)
+	}
+	if len(indexKey) < 32 {
+		return nil, errors.New("盲索引密钥长度至少 32 字节")
+	}
+
+	k := &Keyring{primary: primary, aeads: make(map[string]cipher.AEAD), indexKey: indexKey}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("密钥ID %q 无效", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("密钥 %q 长度必须为 32 字节", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// NewKeyringFromBase64 使用 base64 编码的密钥创建密钥环
+func NewKeyringFromBase64(primary string, keys map[string]string, indexKey string) (*Keyring, error) {
+	decoded := make(map[string][]byte, len(keys))
+	for id, key := range keys {
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %q 不是有效的 base64: %w", id, err)
+		}
+		decoded[id] = b
+	}
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil {
+		return nil, fmt.Errorf("盲索引密钥不是有效的 base64: %w", err)
+	}
+	return NewKeyring(primary, decoded, index)
+}
+
+// Encrypt 使用主密钥加密，空字符串不加密
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 根据密文中的密钥ID解密，历史明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", ErrCiphertext
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrCiphertext
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrCiphertext
+	}
+	return string(plain), nil
+}
+
+// IsCurrent 判断值是否已使用主密钥加密，否则需要迁移或轮换
+func (k *Keyring) IsCurrent(value string) bool {
+	return value == "" || strings.HasPrefix(value, prefix+k.primary+":")
+}
+
+// BlindIndex 计算等值查询用的盲索引，空值返回空字符串
+func (k *Keyring) BlindIndex(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted 判断值是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+var defaultKeyring *Keyring
+
+// SetDefault 设置 String 类型读写数据库时使用的密钥环
+func SetDefault(k *Keyring) {
+	defaultKeyring = k
+}
+
+// Default 当前密钥环，未配置时为 nil
+func Default() *Keyring {
+	return defaultKeyring
+}
+
+// BlindIndex 使用默认密钥环计算盲索引，未配置密钥时返回空字符串
+func BlindIndex(value string) string {
+	if defaultKeyring == nil {
+		return ""
+	}
+	return defaultKeyring.BlindIndex(value)
+}
+
+// String 加密存储的字符串字段，写入数据库时加密，读取时解密
+// 未配置密钥时按明文存储，以兼容未启用加密的部署
+type String string
+
+func (s String) Value() (driver.Value, error) {
+	if defaultKeyring == nil {
+		return string(s), nil
+	}
+	return defaultKeyring.Encrypt(string(s))
+}
+
+func (s *String) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		raw = ""
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("fieldcrypt: unsupported type %T", value)
+	}
+
+	if !IsEncrypted(raw) {
+		*s = String(raw)
+		return nil
+	}
+	if defaultKeyring == nil {
+		return ErrNoKeyring
+	}
+	plain, err := defaultKeyring.Decrypt(raw)
+	if err != nil {
+		return err
+	}
+	*s = String(plain)
+	return nil
+}
@@ -0,0 +1,106 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1, err := k.Encrypt("110101199003074514")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, _ := k.Encrypt("110101199003074514")
+	if !strings.HasPrefix(c1, "enc:k1:") || c1 == c2 {
+		t.Fatalf("unexpected ciphertext: %s %s", c1, c2)
+	}
+
+	plain, err := k.Decrypt(c1)
+	if err != nil || plain != "110101199003074514" {
+		t.Fatalf("decrypt = %q, %v", plain, err)
+	}
+
+	// 历史明文原样返回
+	if plain, _ := k.Decrypt("张三"); plain != "张三" {
+		t.Fatalf("legacy plaintext = %q", plain)
+	}
+
+	tampered := c1[:len(c1)-2] + "AA"
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Fatal("tampered ciphertext should fail")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, _ := NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	c, _ := old.Encrypt("张三")
+
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": key(1), "k2": key(2)}, key(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.IsCurrent(c) {
+		t.Fatal("old ciphertext should not be current")
+	}
+	plain, err := rotated.Decrypt(c)
+	if err != nil || plain != "张三" {
+		t.Fatalf("decrypt with old key = %q, %v", plain, err)
+	}
+	c2, _ := rotated.Encrypt(plain)
+	if !rotated.IsCurrent(c2) {
+		t.Fatal("new ciphertext should be current")
+	}
+
+	// 盲索引与数据密钥无关，轮换后保持不变
+	if old.BlindIndex("110101199003074514") != rotated.BlindIndex("110101199003074514") {
+		t.Fatal("blind index changed after rotation")
+	}
+
+	removed, _ := NewKeyring("k2", map[string][]byte{"k2": key(2)}, key(9))
+	if _, err := removed.Decrypt(c); err == nil {
+		t.Fatal("decrypt with removed key should fail")
+	}
+}
+
+func TestStringValuer(t *testing.T) {
+	SetDefault(nil)
+	v, _ := String("张三").Value()
+	if v != "张三" {
+		t.Fatalf("without keyring value = %v", v)
+	}
+
+	k, _ := NewKeyring("k1", map[string][]byte{"k1": key(1)}, key(9))
+	SetDefault(k)
+	defer SetDefault(nil)
+
+	v, err := String("张三").Value()
+	if err != nil || !IsEncrypted(v.(string)) {
+		t.Fatalf("value = %v, %v", v, err)
+	}
+	var s String
+	if err := s.Scan([]byte(v.(string))); err != nil || s != "张三" {
+		t.Fatalf("scan = %q, %v", s, err)
+	}
+}
+
+func TestNewKeyringInvalid(t *testing.T) {
+	if _, err := NewKeyring("k2", map[string][]byte{"k1": key(1)}, key(9)); err == nil {
+		t.Fatal("missing primary key should fail")
+	}
+	if _, err := NewKeyring("k1", map[string][]byte{"k1": key(1)[:16]}, key(9)); err == nil {
+		t.Fatal("short key should fail")
+	}
+	if _, err := NewKeyring("k1", map[string][]byte{"k1": key(1)}, nil); err == nil {
+		t.Fatal("missing index key should fail")
+	}
+}
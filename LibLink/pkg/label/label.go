@@ -0,0 +1,197 @@
+// Package label 档案与档案盒的条形码、二维码标签
+package label
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+)
+
+// 支持的码制
+const (
+	KindCode128 = "code128"
+	KindQR      = "qr"
+)
+
+var ErrContent = errors.New("内容无法编码为条形码")
+
+// Encode 生成条形码或二维码图片，Code128 只支持可打印 ASCII 字符
+// width、height 为像素，Code128 的宽度会取条宽的整数倍以保证清晰
+func Encode(kind, content string, width, height int) (image.Image, error) {
+	var (
+		code barcode.Barcode
+		err  error
+	)
+	switch kind {
+	case KindCode128:
+		for _, r := range content {
+			if r < 0x20 || r > 0x7e {
+				return nil, ErrContent
+			}
+		}
+		code, err = code128.Encode(content)
+	case KindQR:
+		code, err = qr.Encode(content, qr.M, qr.Auto)
+	default:
+		return nil, fmt.Errorf("unsupported barcode kind %q", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrContent, err)
+	}
+
+	bounds := code.Bounds()
+	if width < bounds.Dx() {
+		width = bounds.Dx()
+	}
+	if height < bounds.Dy() {
+		height = bounds.Dy()
+	}
+	return barcode.Scale(code, width, height)
+}
+
+// WritePNG 将条形码或二维码以 PNG 格式写入 w
+func WritePNG(w io.Writer, kind, content string, width, height int) error {
+	img, err := Encode(kind, content, width, height)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Label 标签内容，Code 同时生成二维码与 Code128 条形码，Lines 为附加的说明文字
+type Label struct {
+	Code  string
+	Lines []string
+}
+
+// Sheet A4 标签纸的版式，单位为毫米
+type Sheet struct {
+	Columns    int
+	Rows       int
+	Width      float64 // 单个标签宽度
+	Height     float64 // 单个标签高度
+	MarginLeft float64
+	MarginTop  float64
+	FontPath   string // 支持中文的 TTF 字体，为空时仅打印 ASCII 文字
+	Border     bool   // 打印标签边框，便于裁切普通纸张
+}
+
+// Write 生成 PDF 标签，超过一页时自动换页
+func (s Sheet) Write(w io.Writer, labels []Label) error {
+	if s.Columns <= 0 || s.Rows <= 0 || s.Width <= 0 || s.Height <= 0 {
+		return errors.New("invalid label sheet layout")
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(0, 0, 0)
+	font := "Helvetica"
+	if s.FontPath != "" {
+		font = "label"
+		pdf.AddUTF8Font(font, "", s.FontPath)
+	}
+
+	perPage := s.Columns * s.Rows
+	for i, l := range labels {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			pdf.SetFont(font, "", 8)
+		}
+		cell := i % perPage
+		x := s.MarginLeft + float64(cell%s.Columns)*s.Width
+		y := s.MarginTop + float64(cell/s.Columns)*s.Height
+		if err := s.draw(pdf, i, x, y, l); err != nil {
+			return err
+		}
+		if err := pdf.Error(); err != nil {
+			return err
+		}
+	}
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+	return pdf.Output(w)
+}
+
+// draw 绘制单个标签：左侧二维码，右侧为编号文字与 Code128 条形码
+func (s Sheet) draw(pdf *fpdf.Fpdf, index int, x, y float64, l Label) error {
+	const pad = 2.0
+	if s.Border {
+		pdf.Rect(x, y, s.Width, s.Height, "D")
+	}
+
+	qrSize := s.Height - 2*pad
+	if err := placeImage(pdf, fmt.Sprintf("qr%d", index), KindQR, l.Code, x+pad, y+pad, qrSize, qrSize); err != nil {
+		return err
+	}
+
+	textX := x + 2*pad + qrSize
+	textW := s.Width - qrSize - 3*pad
+	pdf.SetXY(textX, y+pad)
+	for i, line := range append([]string{l.Code}, l.Lines...) {
+		// 内置字体无法显示中文，未配置字体时跳过
+		if s.FontPath == "" && !isASCII(line) {
+			continue
+		}
+		h := 3.5
+		if i == 0 {
+			h = 4
+		}
+		pdf.SetX(textX)
+		pdf.CellFormat(textW, h, fitText(pdf, line, textW), "", 2, "L", false, 0, "")
+	}
+
+	// Code128 无法编码的编号只打印二维码
+	barH := 8.0
+	err := placeImage(pdf, fmt.Sprintf("bar%d", index), KindCode128, l.Code, textX, y+s.Height-pad-barH, textW, barH)
+	if errors.Is(err, ErrContent) {
+		return nil
+	}
+	return err
+}
+
+// placeImage 生成图片并放置到 PDF 指定位置
+func placeImage(pdf *fpdf.Fpdf, name, kind, content string, x, y, w, h float64) error {
+	// 按 300dpi 生成像素图片
+	img, err := Encode(kind, content, int(w/25.4*300), int(h/25.4*300))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	opt := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, opt, &buf)
+	pdf.ImageOptions(name, x, y, w, h, false, opt, 0, "")
+	return pdf.Error()
+}
+
+// fitText 截断超出宽度的文字
+func fitText(pdf *fpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.GetStringWidth(string(r)+"...") > width {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "..."
+}
+
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package label
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, KindCode128, "HT2024001", 300, 80); err != nil {
+		t.Fatalf("WritePNG(code128) = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("png.Decode() = %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 80 {
+		t.Errorf("code128 bounds = %v, want 300x80", b)
+	}
+
+	qrImg, err := Encode(KindQR, "档案-2024-001", 10, 10)
+	if err != nil {
+		t.Fatalf("Encode(qr) = %v", err)
+	}
+	if b := qrImg.Bounds(); b.Dx() != b.Dy() || b.Dx() < 21 {
+		t.Errorf("qr bounds = %v", b)
+	}
+
+	if _, err := Encode(KindCode128, "档案-2024-001", 300, 80); !errors.Is(err, ErrContent) {
+		t.Errorf("Encode(code128, non-ASCII) = %v, want ErrContent", err)
+	}
+	if _, err := Encode("ean13", "1", 10, 10); err == nil {
+		t.Error("Encode(unknown kind) should fail")
+	}
+}
+
+func TestSheet(t *testing.T) {
+	labels := make([]Label, 25)
+	for i := range labels {
+		labels[i] = Label{Code: "HT2024001", Lines: []string{"Loan contract", "借款合同"}}
+	}
+	labels[3].Code = "档案-001"
+
+	sheet := Sheet{Columns: 3, Rows: 8, Width: 70, Height: 37}
+	var buf bytes.Buffer
+	if err := sheet.Write(&buf, labels); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("Write() output is not a PDF")
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("/Type /Page\n")); n != 2 {
+		t.Errorf("pages = %d, want 2", n)
+	}
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Mailer 邮件发送接口，便于替换为其他实现
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer 通过 SMTP 发送纯文本邮件
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(addr, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer 不实际发送，仅写入日志，用于未配置 SMTP 的环境
+type LogMailer struct {
+	Logger *zap.Logger
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	m.Logger.Info("mail", zap.String("to", to), zap.String("subject", subject), zap.String("body", body))
+	return nil
+}
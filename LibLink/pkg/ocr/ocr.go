@@ -0,0 +1,98 @@
+// Package ocr 扫描件文字识别
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Engine 文字识别引擎接口，便于替换为其他实现
+// path 为本地临时文件，contentType 为 application/pdf、image/jpeg 或 image/tiff
+type Engine interface {
+	Recognize(ctx context.Context, path, contentType string) (string, error)
+}
+
+// Tesseract 调用 tesseract 命令行识别图片，PDF 先用 pdftoppm 逐页转为图片
+type Tesseract struct {
+	Command    string // 默认 tesseract
+	PDFCommand string // 默认 pdftoppm
+	Lang       string // 如 chi_sim+eng，为空时使用 tesseract 的默认语言
+	DPI        int    // PDF 转图片的分辨率，默认 300
+}
+
+func (t *Tesseract) Recognize(ctx context.Context, path, contentType string) (string, error) {
+	if contentType == "application/pdf" {
+		return t.recognizePDF(ctx, path)
+	}
+	// 多页 TIFF 由 tesseract 直接逐页识别
+	return t.recognizeImage(ctx, path)
+}
+
+func (t *Tesseract) recognizeImage(ctx context.Context, path string) (string, error) {
+	args := []string{path, "stdout"}
+	if t.Lang != "" {
+		args = append(args, "-l", t.Lang)
+	}
+	return run(ctx, orDefault(t.Command, "tesseract"), args...)
+}
+
+func (t *Tesseract) recognizePDF(ctx context.Context, path string) (string, error) {
+	dir, err := os.MkdirTemp("", "liblink-ocr-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	dpi := t.DPI
+	if dpi <= 0 {
+		dpi = 300
+	}
+	if _, err := run(ctx, orDefault(t.PDFCommand, "pdftoppm"), "-r", strconv.Itoa(dpi), "-gray", "-png", path, filepath.Join(dir, "page")); err != nil {
+		return "", err
+	}
+
+	// pdftoppm 输出的页码按总页数补零，按文件名排序即为页序
+	pages, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(pages)
+
+	var b strings.Builder
+	for _, page := range pages {
+		text, err := t.recognizeImage(ctx, page)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+	}
+	return b.String(), nil
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("%s: %w: %s", filepath.Base(name), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
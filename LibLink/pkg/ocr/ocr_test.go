@@ -0,0 +1,48 @@
+package ocr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// script 在临时目录中写入可执行的 shell 脚本，代替真实的命令行工具
+func script(t *testing.T, name, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTesseract(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	ctx := context.Background()
+	tess := &Tesseract{
+		Command: script(t, "tesseract", `echo "$(basename "$1") $2 $3 $4"`),
+		// 生成两页，文件名与 pdftoppm 一致
+		PDFCommand: script(t, "pdftoppm", `for a; do last=$a; done; touch "$last-2.png" "$last-1.png"`),
+		Lang:       "chi_sim+eng",
+	}
+
+	text, err := tess.Recognize(ctx, "/tmp/scan.jpg", "image/jpeg")
+	if err != nil || text != "scan.jpg stdout -l chi_sim+eng\n" {
+		t.Errorf("Recognize() image = %q, %v", text, err)
+	}
+
+	text, err = tess.Recognize(ctx, "/tmp/scan.pdf", "application/pdf")
+	if err != nil || text != "page-1.png stdout -l chi_sim+eng\npage-2.png stdout -l chi_sim+eng\n" {
+		t.Errorf("Recognize() pdf = %q, %v", text, err)
+	}
+
+	failing := &Tesseract{Command: script(t, "tesseract", "echo 'Error opening data file' >&2; exit 1")}
+	if _, err := failing.Recognize(ctx, "/tmp/scan.jpg", "image/jpeg"); err == nil || !strings.Contains(err.Error(), "Error opening data file") {
+		t.Errorf("Recognize() error = %v", err)
+	}
+}
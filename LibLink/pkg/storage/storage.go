@@ -0,0 +1,38 @@
+// Package storage 档案附件的对象存储，支持本地文件系统与 S3 兼容存储
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("对象不存在")
+	ErrInvalidKey = errors.New("对象键无效")
+)
+
+// Storage 对象存储接口，key 为 / 分隔的相对路径
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey 拒绝绝对路径与包含 .. 的键，避免越出存储目录
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	if path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
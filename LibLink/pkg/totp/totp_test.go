@@ -0,0 +1,44 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+// RFC 4226 附录 D 测试向量
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for i, code := range expected {
+		assert.Equal(t, code, HOTP(key, uint64(i), 6))
+	}
+}
+
+// RFC 6238 附录 B 测试向量（SHA1）
+func TestTOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	assert.Equal(t, "94287082", HOTP(key, Counter(time.Unix(59, 0)), 8))
+	assert.Equal(t, "07081804", HOTP(key, Counter(time.Unix(1111111109, 0)), 8))
+	assert.Equal(t, "65353130", HOTP(key, Counter(time.Unix(20000000000, 0)), 8))
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.Equal(t, nil, err)
+
+	now := time.Now()
+	code, err := Code(secret, now.Add(-Period*time.Second))
+	assert.Equal(t, nil, err)
+
+	counter, ok := Validate(secret, code, now, 1)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, Counter(now)-1, counter)
+
+	_, ok = Validate(secret, code, now, 0)
+	assert.Equal(t, false, ok)
+
+	_, ok = Validate(secret, "12345", now, 1)
+	assert.Equal(t, false, ok)
+}